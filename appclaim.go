@@ -0,0 +1,71 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	lblog "github.com/lidstromberg/log"
+)
+
+//SetAppClaimT adds or updates an appclaim holding any json value (includes token refresh)
+//the value is stored as json within the token, so it is read back with GetAppClaimT rather than as an encoded string
+func SetAppClaimT[T any](ctx context.Context, sp SessProvider, sessionID string, appName string, appClaim T) (string, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "SetAppClaimT", "info", "start")
+	}
+
+	//round trip the value so the claims hold plain json values, as they do once the token is parsed
+	data, err := json.Marshal(appClaim)
+	if err != nil {
+		return "", fmt.Errorf("%w: appclaim %s cannot be encoded: %v", ErrAppClaimType, appName, err)
+	}
+
+	var val interface{}
+	if err := json.Unmarshal(data, &val); err != nil {
+		return "", fmt.Errorf("%w: appclaim %s cannot be encoded: %v", ErrAppClaimType, appName, err)
+	}
+
+	tokenString, err := sp.ModifyClaims(ctx, sessionID, func(ce *ClaimsEditor) error {
+		return ce.Set(appName, val)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "SetAppClaimT", "info", "end")
+	}
+
+	return tokenString, nil
+}
+
+//GetAppClaimT returns an appclaim decoded into T, an appclaim which does not decode into T returns ErrAppClaimType
+func GetAppClaimT[T any](ctx context.Context, sv SessVerifier, sessionID string, appName string) (T, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "GetAppClaimT", "info", "start")
+	}
+
+	var out T
+
+	clm, err := sv.GetJwtClaimElement(ctx, sessionID, appName)
+	if err != nil {
+		return out, err
+	}
+
+	data, err := json.Marshal(clm)
+	if err != nil {
+		return out, fmt.Errorf("%w: appclaim %s cannot be read: %v", ErrAppClaimType, appName, err)
+	}
+
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("%w: appclaim %s is not a %T: %v", ErrAppClaimType, appName, out, err)
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "GetAppClaimT", "info", "end")
+	}
+
+	return out, nil
+}
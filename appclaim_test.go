@@ -0,0 +1,85 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+)
+
+type testProfile struct {
+	Theme  string   `json:"theme"`
+	Pinned []string `json:"pinned"`
+	Count  int      `json:"count"`
+}
+
+func Test_AppClaimT(t *testing.T) {
+	ctx := context.Background()
+
+	//the typed appclaim functions only need the provider interfaces
+	var sm1 SessProvider
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := testProfile{Theme: "dark", Pinned: []string{"a", "b"}, Count: 3}
+
+	sess, err = SetAppClaimT(ctx, sm1, sess, "testapp1.profile", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err = SetAppClaimT(ctx, sm1, sess, "testapp1.limit", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := GetAppClaimT[testProfile](ctx, sm1, sess, "testapp1.profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Theme != in.Theme || len(out.Pinned) != 2 || out.Count != in.Count {
+		t.Fatalf("expected %+v, got %+v", in, out)
+	}
+
+	limit, err := GetAppClaimT[int](ctx, sm1, sess, "testapp1.limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if limit != 42 {
+		t.Fatalf("expected 42, got %d", limit)
+	}
+
+	//the value is stored as json, not as an encoded string
+	clms, err := sm1.GetJwtClaim(ctx, sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := clms.AppClaims["testapp1.profile"].(map[string]interface{}); !ok {
+		t.Fatalf("expected a json object, got %T", clms.AppClaims["testapp1.profile"])
+	}
+
+	if _, err := GetAppClaimT[string](ctx, sm1, sess, "testapp1.limit"); !errors.Is(err, ErrAppClaimType) {
+		t.Fatalf("expected %v, got %v", ErrAppClaimType, err)
+	}
+
+	if _, err := GetAppClaimT[int](ctx, sm1, sess, "testapp1.missing"); err != ErrClaimElementNotExist {
+		t.Fatalf("expected %v, got %v", ErrClaimElementNotExist, err)
+	}
+
+	if _, err := SetAppClaimT(ctx, sm1, sess, "testapp1.bad", make(chan int)); !errors.Is(err, ErrAppClaimType) {
+		t.Fatalf("expected %v, got %v", ErrAppClaimType, err)
+	}
+}
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	lblog "github.com/lidstromberg/log"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//NewAudienceToken returns a token for a single audience, down-scoped from an existing session
+//if the session is issued for a list of audiences the new audience has to be one of them
+//the token is an access token which cannot be refreshed, and expires after the lifetime or with the session if that is sooner
+//a lifetime of zero keeps the session expiry
+func (sessMgr *SessMgr) NewAudienceToken(ctx context.Context, sessionID string, audience string, lifetime time.Duration) (string, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "NewAudienceToken", "info", "start")
+	}
+
+	if audience == "" {
+		return "", ErrJwtAudience
+	}
+
+	//extract the token
+	clms, err := sessMgr.extractOwnJwt(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	//a token can be narrowed to one of its audiences, but not widened to another
+	if len(clms.Audience) > 0 && !containsString(clms.Audience, audience) {
+		return "", ErrJwtAudience
+	}
+
+	now := sessMgr.clock.Now()
+
+	exp := now.Add(lifetime)
+	if clms.ExpiresAt != nil && (lifetime <= 0 || clms.ExpiresAt.Before(exp)) {
+		exp = clms.ExpiresAt.Time
+	}
+
+	//the jti and token family are kept, so revoking the session also revokes the down-scoped token
+	scoped := clms.clone()
+	scoped.Audience = jwt.ClaimStrings{audience}
+	scoped.TokenType = ConstTokenAccess
+	scoped.ExpiresAt = jwt.NewNumericDate(exp)
+	scoped.IssuedAt = jwt.NewNumericDate(now)
+	scoped.NotBefore = jwt.NewNumericDate(now)
+
+	tokenString, err := sessMgr.signClaims(scoped)
+	if err != nil {
+		return "", err
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "NewAudienceToken", "info", "end")
+	}
+
+	return tokenString, nil
+}
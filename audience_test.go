@@ -0,0 +1,114 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+)
+
+func Test_Audience(t *testing.T) {
+	ctx := context.Background()
+
+	keys := createTestKeys(t)
+
+	t.Setenv("JWT_AUDIENCE", "web, billing")
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), keys["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clms, err := sm1.GetJwtClaim(ctx, sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clms.Audience) != 2 || clms.Audience[0] != "web" || clms.Audience[1] != "billing" {
+		t.Fatalf("expected the config audiences, got %v", clms.Audience)
+	}
+
+	//verifiers only accept tokens issued for their audience
+	cases := []struct {
+		name string
+		aud  []string
+		err  error
+	}{
+		{"matching audience", []string{"billing"}, nil},
+		{"one of several", []string{"reports", "web"}, nil},
+		{"other audience", []string{"reports"}, ErrJwtAudience},
+	}
+
+	for _, tc := range cases {
+		vf, err := NewVerifier(ctx, lbcf.NewConfig(ctx), sm1.ring, WithAudience(tc.aud...))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := vf.GetJwtClaim(ctx, sess); err != tc.err {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.err, err)
+		}
+	}
+
+	//a down-scoped token is only accepted by its audience
+	scoped, err := sm1.NewAudienceToken(ctx, sess, "billing", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	billing, err := NewVerifier(ctx, lbcf.NewConfig(ctx), sm1.ring, WithAudience("billing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sclms, err := billing.GetJwtClaim(ctx, scoped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sclms.ID != clms.ID || sclms.TokenType != ConstTokenAccess {
+		t.Fatalf("unexpected down-scoped claims: %+v", sclms)
+	}
+
+	if d := time.Until(sclms.ExpiresAt.Time); d > time.Minute {
+		t.Fatalf("expected the down-scoped lifetime, got %v", d)
+	}
+
+	web, err := NewVerifier(ctx, lbcf.NewConfig(ctx), sm1.ring, WithAudience("web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := web.GetJwtClaim(ctx, scoped); err != ErrJwtAudience {
+		t.Fatalf("expected %v, got %v", ErrJwtAudience, err)
+	}
+
+	//a token cannot be widened to an audience it was not issued for
+	if _, err := sm1.NewAudienceToken(ctx, sess, "reports", time.Minute); err != ErrJwtAudience {
+		t.Fatalf("expected %v, got %v", ErrJwtAudience, err)
+	}
+
+	//down-scoped tokens cannot be refreshed
+	if _, ok := (<-sm1.RefreshSession(ctx, scoped)).(error); !ok {
+		t.Fatal("a down-scoped token should not be refreshed")
+	}
+
+	//without an audience the aud claim is not checked
+	t.Setenv("JWT_AUDIENCE", "")
+
+	sm2, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), keys["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm2.GetJwtClaim(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+}
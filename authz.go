@@ -0,0 +1,384 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"path"
+	"reflect"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	lblog "github.com/lidstromberg/log"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	//EffectAllow grants the request if no rule denies it
+	EffectAllow = "allow"
+	//EffectDeny refuses the request whatever other rules allow
+	EffectDeny = "deny"
+)
+
+const (
+	//ConditionEquals holds if the attribute equals the value
+	ConditionEquals = "eq"
+	//ConditionNotEquals holds if the attribute is set and does not equal the value
+	ConditionNotEquals = "ne"
+	//ConditionIn holds if the attribute equals one of the values
+	ConditionIn = "in"
+	//ConditionExists holds if the attribute is set
+	ConditionExists = "exists"
+)
+
+//attributeSources are the prefixes of condition attributes
+//claim.<name> reads a session claim, app.<name> an appclaim and request.<name> a request attribute
+var attributeSources = map[string]bool{"claim": true, "app": true, "request": true}
+
+//requestAttributesKey is the context key of the request attributes
+type requestAttributesKey struct{}
+
+//WithRequestAttributes returns a copy of ctx which carries attributes of the request being authorized, such as the resource owner
+func WithRequestAttributes(ctx context.Context, attrs map[string]interface{}) context.Context {
+	return context.WithValue(ctx, requestAttributesKey{}, attrs)
+}
+
+//RequestAttributesFromContext returns the request attributes carried by ctx
+func RequestAttributesFromContext(ctx context.Context) map[string]interface{} {
+	attrs, _ := ctx.Value(requestAttributesKey{}).(map[string]interface{})
+	return attrs
+}
+
+//AuthzRequest is an authorization request passed to a policy evaluator
+type AuthzRequest struct {
+	//Claims are the verified session claims
+	Claims *SessionClaims
+	//Roles are the roles held by the session and every role they imply
+	Roles []string
+	//Action is the operation requested, eg invoice:read
+	Action string
+	//Resource is the target of the action, eg invoices/42
+	Resource string
+	//Attributes are the request attributes carried by the context
+	Attributes map[string]interface{}
+}
+
+//Decision is the outcome of an authorization request
+type Decision struct {
+	Allowed bool
+	//Rule is the name of the deciding rule, empty if no rule matched
+	Rule string
+	//Reason explains the decision
+	Reason string
+}
+
+//PolicyEvaluator decides authorization requests
+type PolicyEvaluator interface {
+	Evaluate(ctx context.Context, req *AuthzRequest) (*Decision, error)
+}
+
+//PolicyCondition is an attribute test of a policy rule
+type PolicyCondition struct {
+	//Attr is the attribute tested, eg claim.aid, app.testapp1.tier or request.owner
+	Attr string
+	//Op is one of the Condition constants
+	Op string
+	//Value is compared with the attribute, a list for ConditionIn
+	Value interface{}
+	//ValueAttr compares with another attribute in place of Value, eg request.owner equals claim.aid
+	ValueAttr string
+}
+
+//PolicyRule grants or refuses actions on resources
+//every set field has to match for the rule to apply, patterns use path.Match syntax (eg invoices/*)
+type PolicyRule struct {
+	Name string
+	//Effect is EffectAllow or EffectDeny
+	Effect string
+	//Actions are action patterns, empty matches any action
+	Actions []string
+	//Resources are resource patterns, empty matches any resource
+	Resources []string
+	//Roles are roles of which the session must hold one, empty matches any session
+	Roles []string
+	//Conditions must all hold
+	Conditions []PolicyCondition
+}
+
+//RuleEvaluator is a policy evaluator over a list of rules
+//a matching deny rule refuses the request, otherwise a matching allow rule grants it, otherwise it is refused
+type RuleEvaluator struct {
+	rules []PolicyRule
+}
+
+//NewRuleEvaluator creates a rule evaluator, every rule is checked before it is used
+func NewRuleEvaluator(rules ...PolicyRule) (*RuleEvaluator, error) {
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RuleEvaluator{rules: rules}, nil
+}
+
+//validate checks the effect, patterns and conditions of the rule
+func (rule *PolicyRule) validate() error {
+	if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+		return ErrPolicyRuleInvalid
+	}
+
+	for _, pattern := range append(append([]string{}, rule.Actions...), rule.Resources...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return ErrPolicyRuleInvalid
+		}
+	}
+
+	for _, cond := range rule.Conditions {
+		if !validAttribute(cond.Attr) || (cond.ValueAttr != "" && !validAttribute(cond.ValueAttr)) {
+			return ErrPolicyRuleInvalid
+		}
+
+		switch cond.Op {
+		case ConditionEquals, ConditionNotEquals, ConditionExists:
+		case ConditionIn:
+			if cond.ValueAttr != "" {
+				break
+			}
+			if _, ok := cond.Value.([]interface{}); !ok {
+				if _, ok := cond.Value.([]string); !ok {
+					return ErrPolicyRuleInvalid
+				}
+			}
+		default:
+			return ErrPolicyRuleInvalid
+		}
+	}
+
+	return nil
+}
+
+//validAttribute returns true if the attribute has a known source and a name
+func validAttribute(attr string) bool {
+	src, name, ok := strings.Cut(attr, ".")
+	return ok && name != "" && attributeSources[src]
+}
+
+//Evaluate decides the request, deny rules override allow rules and nothing is allowed by default
+func (re *RuleEvaluator) Evaluate(ctx context.Context, req *AuthzRequest) (*Decision, error) {
+	var allow *PolicyRule
+
+	for i := range re.rules {
+		rule := &re.rules[i]
+
+		if !rule.matches(req) {
+			continue
+		}
+
+		if rule.Effect == EffectDeny {
+			return &Decision{Allowed: false, Rule: rule.Name, Reason: fmt.Sprintf("%s on %s denied by rule %s", req.Action, req.Resource, rule.Name)}, nil
+		}
+
+		if allow == nil {
+			allow = rule
+		}
+	}
+
+	if allow != nil {
+		return &Decision{Allowed: true, Rule: allow.Name, Reason: fmt.Sprintf("%s on %s allowed by rule %s", req.Action, req.Resource, allow.Name)}, nil
+	}
+
+	return &Decision{Allowed: false, Reason: fmt.Sprintf("no rule allows %s on %s", req.Action, req.Resource)}, nil
+}
+
+//matches returns true if the rule applies to the request
+func (rule *PolicyRule) matches(req *AuthzRequest) bool {
+	if !matchAny(rule.Actions, req.Action) || !matchAny(rule.Resources, req.Resource) {
+		return false
+	}
+
+	if len(rule.Roles) > 0 {
+		held := false
+		for _, role := range rule.Roles {
+			if holdsRole(req.Roles, role) {
+				held = true
+				break
+			}
+		}
+
+		if !held {
+			return false
+		}
+	}
+
+	for _, cond := range rule.Conditions {
+		if !cond.holds(req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//matchAny returns true if there are no patterns or one of them matches the value
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+//holds tests the condition against the request
+func (cond *PolicyCondition) holds(req *AuthzRequest) bool {
+	val, ok := req.attribute(cond.Attr)
+
+	if cond.Op == ConditionExists {
+		return ok
+	}
+
+	if !ok {
+		return false
+	}
+
+	want := cond.Value
+	if cond.ValueAttr != "" {
+		if want, ok = req.attribute(cond.ValueAttr); !ok {
+			return false
+		}
+	}
+
+	switch cond.Op {
+	case ConditionEquals:
+		return sameValue(val, want)
+	case ConditionNotEquals:
+		return !sameValue(val, want)
+	case ConditionIn:
+		switch list := want.(type) {
+		case []interface{}:
+			for _, v := range list {
+				if sameValue(val, v) {
+					return true
+				}
+			}
+		case []string:
+			for _, v := range list {
+				if sameValue(val, v) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+//attribute reads a claim, appclaim or request attribute
+func (req *AuthzRequest) attribute(attr string) (interface{}, bool) {
+	src, name, _ := strings.Cut(attr, ".")
+
+	switch src {
+	case "claim":
+		return req.Claims.Claim(name)
+	case "app":
+		val, ok := req.Claims.AppClaims[name]
+		return val, ok
+	case "request":
+		val, ok := req.Attributes[name]
+		return val, ok
+	}
+
+	return nil, false
+}
+
+//sameValue compares attribute values by type and value, numbers are compared by value so json numbers equal go integers
+func sameValue(a, b interface{}) bool {
+	x, xok := numericValue(a)
+	y, yok := numericValue(b)
+
+	if xok || yok {
+		return xok && yok && x.Cmp(y) == 0
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+//numericValue returns the exact value of a number, time claims are read as their unix time
+func numericValue(v interface{}) (*big.Float, bool) {
+	switch n := v.(type) {
+	case nil:
+		return nil, false
+	case json.Number:
+		f, ok := new(big.Float).SetString(n.String())
+		return f, ok
+	case *jwt.NumericDate:
+		if n == nil {
+			return nil, false
+		}
+		return new(big.Float).SetInt64(n.Unix()), true
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Float).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Float).SetUint64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) {
+			return nil, false
+		}
+		return new(big.Float).SetFloat64(f), true
+	}
+
+	return nil, false
+}
+
+//Authorize decides whether the session can perform the action on the resource
+//the request attributes are read from ctx, see WithRequestAttributes
+func (verifier *Verifier) Authorize(ctx context.Context, sessionID, action, resource string) (*Decision, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "Authorize", "info", "start")
+	}
+
+	if verifier.evaluator == nil {
+		return nil, ErrPolicyNotSet
+	}
+
+	//extract the token
+	clms, err := verifier.extractJwt(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &AuthzRequest{
+		Claims:     clms,
+		Roles:      verifier.roles.grants(clms.Roles),
+		Action:     action,
+		Resource:   resource,
+		Attributes: RequestAttributesFromContext(ctx),
+	}
+
+	dec, err := verifier.evaluator.Evaluate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "Authorize", "info", dec.Reason)
+		lblog.LogEvent("Verifier", "Authorize", "info", "end")
+	}
+
+	return dec, nil
+}
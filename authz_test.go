@@ -0,0 +1,153 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/net/context"
+)
+
+func createTestRules(t *testing.T) *RuleEvaluator {
+	re, err := NewRuleEvaluator(
+		PolicyRule{
+			Name:      "editors-write",
+			Effect:    EffectAllow,
+			Actions:   []string{"invoice:*"},
+			Resources: []string{"invoices/*"},
+			Roles:     []string{"testapp2"},
+		},
+		PolicyRule{
+			Name:       "owners-read",
+			Effect:     EffectAllow,
+			Actions:    []string{"report:read"},
+			Resources:  []string{"reports/*"},
+			Conditions: []PolicyCondition{{Attr: "request.owner", Op: ConditionEquals, ValueAttr: "claim.aid"}},
+		},
+		PolicyRule{
+			Name:       "premium-export",
+			Effect:     EffectAllow,
+			Actions:    []string{"report:export"},
+			Conditions: []PolicyCondition{{Attr: "app.testapp1.tier", Op: ConditionIn, Value: []string{"gold", "platinum"}}},
+		},
+		PolicyRule{
+			Name:       "frozen",
+			Effect:     EffectDeny,
+			Resources:  []string{"invoices/*"},
+			Conditions: []PolicyCondition{{Attr: "request.frozen", Op: ConditionEquals, Value: true}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return re
+}
+func Test_Authorize(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithPolicyEvaluator(createTestRules(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gold, err := sm1.SetAppClaim(ctx, sess, "testapp1.tier", "gold")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		sess     string
+		action   string
+		resource string
+		attrs    map[string]interface{}
+		allowed  bool
+		rule     string
+	}{
+		{"role allows", sess, "invoice:write", "invoices/42", nil, true, "editors-write"},
+		{"deny overrides allow", sess, "invoice:write", "invoices/42", map[string]interface{}{"frozen": true}, false, "frozen"},
+		{"owner matches claim", sess, "report:read", "reports/7", map[string]interface{}{"owner": "dummyUser1"}, true, "owners-read"},
+		{"owner does not match", sess, "report:read", "reports/7", map[string]interface{}{"owner": "dummyUser2"}, false, ""},
+		{"appclaim in list", gold, "report:export", "reports/7", nil, true, "premium-export"},
+		{"appclaim missing", sess, "report:export", "reports/7", nil, false, ""},
+		{"default deny", sess, "user:delete", "users/1", nil, false, ""},
+	}
+
+	for _, tc := range cases {
+		dec, err := sm1.Authorize(WithRequestAttributes(ctx, tc.attrs), tc.sess, tc.action, tc.resource)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if dec.Allowed != tc.allowed || dec.Rule != tc.rule {
+			t.Fatalf("%s: expected allowed %v by %q, got %+v", tc.name, tc.allowed, tc.rule, dec)
+		}
+
+		if dec.Reason == "" {
+			t.Fatalf("%s: decision has no reason", tc.name)
+		}
+	}
+
+	if _, err := sm1.Authorize(ctx, sess+"x", "invoice:write", "invoices/42"); err == nil {
+		t.Fatal("an invalid token should not be authorized")
+	}
+
+	sm2, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm2.Authorize(ctx, sess, "invoice:write", "invoices/42"); err != ErrPolicyNotSet {
+		t.Fatalf("expected %v, got %v", ErrPolicyNotSet, err)
+	}
+}
+func Test_PolicyRuleInvalid(t *testing.T) {
+	rules := []PolicyRule{
+		{Name: "effect", Effect: "maybe"},
+		{Name: "pattern", Effect: EffectAllow, Actions: []string{"["}},
+		{Name: "source", Effect: EffectAllow, Conditions: []PolicyCondition{{Attr: "header.x", Op: ConditionExists}}},
+		{Name: "op", Effect: EffectAllow, Conditions: []PolicyCondition{{Attr: "claim.aid", Op: "like"}}},
+		{Name: "list", Effect: EffectAllow, Conditions: []PolicyCondition{{Attr: "claim.aid", Op: ConditionIn, Value: "a"}}},
+	}
+
+	for _, rule := range rules {
+		if _, err := NewRuleEvaluator(rule); err != ErrPolicyRuleInvalid {
+			t.Fatalf("%s: expected %v, got %v", rule.Name, ErrPolicyRuleInvalid, err)
+		}
+	}
+}
+func Test_SameValue(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b interface{}
+		same bool
+	}{
+		{"equal strings", "gold", "gold", true},
+		{"json number and integer", float64(3), 3, true},
+		{"integer types", int64(3), uint8(3), true},
+		{"json.Number and integer", json.Number("42"), 42, true},
+		{"different numbers", float64(3.5), 3, false},
+		{"bool and string", true, "true", false},
+		{"nil and string", nil, "<nil>", false},
+		{"nil and nil", nil, nil, true},
+		{"list and string", []string{"a"}, "[a]", false},
+		{"number and string", 3, "3", false},
+		{"equal lists", []interface{}{"a", "b"}, []interface{}{"a", "b"}, true},
+		{"time claim and unix time", jwt.NewNumericDate(time.Unix(1700000000, 0)), float64(1700000000), true},
+	}
+
+	for _, tc := range cases {
+		if sameValue(tc.a, tc.b) != tc.same || sameValue(tc.b, tc.a) != tc.same {
+			t.Fatalf("%s: expected same %v", tc.name, tc.same)
+		}
+	}
+}
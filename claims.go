@@ -0,0 +1,201 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//sessionClaimNames are the claims held in the typed fields of SessionClaims, appclaims cannot use these names
+var sessionClaimNames = map[string]bool{
+	"iss":              true,
+	"sub":              true,
+	"aud":              true,
+	"exp":              true,
+	"nbf":              true,
+	"iat":              true,
+	ConstJwtID:         true,
+	ConstJwtRole:       true,
+	ConstJwtAccID:      true,
+	ConstJwtEml:        true,
+	ConstJwtAuth:       true,
+	ConstJwtType:       true,
+	ConstJwtFamily:     true,
+	ConstJwtGeneration: true,
+	ConstJwtApp:        true,
+}
+
+//ReservedClaimError occurs if an appclaim change targets a registered or session claim
+type ReservedClaimError struct {
+	Claim string
+}
+
+//Error returns the error message
+func (e *ReservedClaimError) Error() string {
+	return fmt.Sprintf("the claim %s is reserved and cannot be changed as an appclaim", e.Claim)
+}
+
+//checkAppClaimName returns a ReservedClaimError if the name is reserved
+func checkAppClaimName(appName string) error {
+	if sessionClaimNames[appName] {
+		return &ReservedClaimError{Claim: appName}
+	}
+
+	return nil
+}
+
+//SessionClaims are the claims of a session token
+type SessionClaims struct {
+	//RegisteredClaims holds iss, sub, aud, exp, nbf, iat and the session id (jti)
+	jwt.RegisteredClaims
+	//Roles are the roles held by the session (rle)
+	Roles []string `json:"rle,omitempty"`
+	//AccountID is the user account id (aid)
+	AccountID string `json:"aid,omitempty"`
+	//Email is the user email (eml)
+	Email string `json:"eml,omitempty"`
+	//AuthTime is the original authentication time, kept through every refresh (auth_time)
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	//TokenType is only set on access/refresh token pairs (typ)
+	TokenType string `json:"typ,omitempty"`
+	//Family is the token family id shared by the tokens of a refresh chain (fam)
+	Family string `json:"fam,omitempty"`
+	//Generation is the refresh generation within the token family (gen)
+	Generation int64 `json:"gen,omitempty"`
+	//AppClaims are the claims added by applications, keyed by claim name (app)
+	AppClaims map[string]interface{} `json:"app,omitempty"`
+	//roleToken is the delimited role string of a token issued before roles were a list, split by the verifier
+	roleToken string
+}
+
+//sessionClaimsJSON is SessionClaims without its json methods
+type sessionClaimsJSON SessionClaims
+
+//UnmarshalJSON reads the session claims into their fields and the app object into the appclaims
+//tokens issued before the app object was introduced hold their appclaims alongside the session claims, these are read as appclaims too
+//tokens issued before roles were a list hold a delimited role string, which is kept for the verifier to split
+//a session claim of the wrong type is an error
+func (sc *SessionClaims) UnmarshalJSON(data []byte) error {
+	all := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+
+	var roleToken string
+	if rle, ok := all[ConstJwtRole]; ok && json.Unmarshal(rle, &roleToken) == nil {
+		delete(all, ConstJwtRole)
+
+		var err error
+		if data, err = json.Marshal(all); err != nil {
+			return err
+		}
+	}
+
+	var tc sessionClaimsJSON
+	if err := json.Unmarshal(data, &tc); err != nil {
+		return err
+	}
+
+	tc.roleToken = roleToken
+
+	for k, raw := range all {
+		if sessionClaimNames[k] {
+			continue
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+
+		if tc.AppClaims == nil {
+			tc.AppClaims = make(map[string]interface{})
+		}
+		//the app object wins over a flat claim of the same name
+		if _, ok := tc.AppClaims[k]; !ok {
+			tc.AppClaims[k] = v
+		}
+	}
+
+	*sc = SessionClaims(tc)
+
+	return nil
+}
+
+//splitRoleToken moves a legacy delimited role string into the role list
+func (sc *SessionClaims) splitRoleToken(delimiter string) {
+	if sc.roleToken == "" {
+		return
+	}
+
+	for _, role := range strings.Split(sc.roleToken, delimiter) {
+		if role != "" {
+			sc.Roles = append(sc.Roles, role)
+		}
+	}
+
+	sc.roleToken = ""
+}
+
+//HasRole returns true if the session holds the role, or a wildcard role which matches it (eg billing:*)
+//the role hierarchy is not applied, use the verifier role checks for implied roles
+func (sc *SessionClaims) HasRole(roleName string) bool {
+	return holdsRole(sc.Roles, roleName)
+}
+
+//Claim returns a claim by name, session claims are returned as their typed field value
+func (sc *SessionClaims) Claim(name string) (interface{}, bool) {
+	switch name {
+	case "iss":
+		return sc.Issuer, sc.Issuer != ""
+	case "sub":
+		return sc.Subject, sc.Subject != ""
+	case "aud":
+		return sc.Audience, len(sc.Audience) > 0
+	case "exp":
+		return sc.ExpiresAt, sc.ExpiresAt != nil
+	case "nbf":
+		return sc.NotBefore, sc.NotBefore != nil
+	case "iat":
+		return sc.IssuedAt, sc.IssuedAt != nil
+	case ConstJwtID:
+		return sc.ID, sc.ID != ""
+	case ConstJwtRole:
+		return sc.Roles, len(sc.Roles) > 0
+	case ConstJwtAccID:
+		return sc.AccountID, sc.AccountID != ""
+	case ConstJwtEml:
+		return sc.Email, sc.Email != ""
+	case ConstJwtAuth:
+		return sc.AuthTime, sc.AuthTime != nil
+	case ConstJwtType:
+		return sc.TokenType, sc.TokenType != ""
+	case ConstJwtFamily:
+		return sc.Family, sc.Family != ""
+	case ConstJwtGeneration:
+		return sc.Generation, sc.Family != ""
+	case ConstJwtApp:
+		return sc.AppClaims, len(sc.AppClaims) > 0
+	}
+
+	clm, ok := sc.AppClaims[name]
+
+	return clm, ok
+}
+
+//clone returns a copy of the claims which does not share the app claims map
+func (sc *SessionClaims) clone() *SessionClaims {
+	cp := *sc
+	cp.Roles = append([]string(nil), sc.Roles...)
+
+	if sc.AppClaims != nil {
+		cp.AppClaims = make(map[string]interface{}, len(sc.AppClaims))
+		for k, v := range sc.AppClaims {
+			cp.AppClaims[k] = v
+		}
+	}
+
+	return &cp
+}
@@ -0,0 +1,161 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/net/context"
+)
+
+func Test_SessionClaimsJSON(t *testing.T) {
+	clms := createBaseClaims()
+	clms.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	clms.AppClaims = map[string]interface{}{"testapp1.editor": "ready"}
+
+	data, err := json.Marshal(clms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &SessionClaims{}
+	if err := json.Unmarshal(data, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.ID != clms.ID || len(out.Roles) != len(clms.Roles) || out.AccountID != clms.AccountID || out.Email != clms.Email {
+		t.Fatalf("session claims not kept: %+v", out)
+	}
+
+	if len(out.AppClaims) != 1 || out.AppClaims["testapp1.editor"] != "ready" {
+		t.Fatalf("unexpected app claims: %v", out.AppClaims)
+	}
+
+	//appclaims are confined to the app object
+	flat := make(map[string]interface{})
+	if err := json.Unmarshal(data, &flat); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := flat["testapp1.editor"]; ok {
+		t.Fatal("appclaims should not be written alongside the session claims")
+	}
+
+	if _, ok := flat[ConstJwtApp].(map[string]interface{}); !ok {
+		t.Fatalf("expected an app object, got %v", flat[ConstJwtApp])
+	}
+
+	//tokens with flat appclaims are still read, the app object wins over a flat claim
+	legacy := &SessionClaims{}
+	if err := json.Unmarshal([]byte(`{"jti":"s1","testapp1.editor":"flat","testapp1.viewer":"flat","app":{"testapp1.editor":"nested"}}`), legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	if legacy.AppClaims["testapp1.editor"] != "nested" || legacy.AppClaims["testapp1.viewer"] != "flat" {
+		t.Fatalf("unexpected app claims: %v", legacy.AppClaims)
+	}
+
+	if err := json.Unmarshal([]byte(`{"jti":"s1","rle":123}`), &SessionClaims{}); err == nil {
+		t.Fatal("a role of the wrong type should not decode")
+	}
+	wild := &SessionClaims{Roles: []string{"testapp1", "billing:*"}}
+	if !wild.HasRole("testapp1") || !wild.HasRole("billing:read") || wild.HasRole("testapp2") {
+		t.Fatalf("unexpected role checks for %v", wild.Roles)
+	}
+}
+func Test_MalformedClaims(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(clms jwt.MapClaims) string {
+		sk := sm1.ring.signingKey()
+
+		signer := jwt.NewWithClaims(sk.Method, clms)
+		signer.Header["kid"] = sk.KeyID
+
+		token, err := signer.SignedString(sk.SignKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return token
+	}
+
+	exp := time.Now().Add(time.Minute).Unix()
+
+	//a role which is not a string is an error, not a panic
+	if _, err := sm1.CheckUserRole(ctx, sign(jwt.MapClaims{"iss": sm1.issuer, "jti": "s1", "exp": exp, "rle": 123}), "testapp1"); err == nil {
+		t.Fatal("expected an error for a malformed role")
+	}
+
+	if _, err := sm1.CheckUserRole(ctx, sign(jwt.MapClaims{"iss": sm1.issuer, "jti": "s1", "exp": exp}), "testapp1"); err != ErrClaimElementNotExist {
+		t.Fatalf("expected %v for a missing role, got %v", ErrClaimElementNotExist, err)
+	}
+
+	if _, err := sm1.GetJwtClaim(ctx, sign(jwt.MapClaims{"iss": sm1.issuer, "jti": 1, "exp": exp})); err == nil {
+		t.Fatal("expected an error for a malformed session id")
+	}
+}
+func Test_ReservedClaims(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"exp", "iss", ConstJwtID, ConstJwtRole, ConstJwtAccID, ConstJwtEml, ConstJwtAuth, ConstJwtApp} {
+		var rce *ReservedClaimError
+
+		if _, err := sm1.SetAppClaim(ctx, sess, name, "x"); !errors.As(err, &rce) || rce.Claim != name {
+			t.Fatalf("set %s: expected a reserved claim error, got %v", name, err)
+		}
+
+		if _, err := sm1.DeleteAppClaim(ctx, sess, name); !errors.As(err, &rce) {
+			t.Fatalf("delete %s: expected a reserved claim error, got %v", name, err)
+		}
+	}
+
+	//a flat appclaim of an older token moves into the app object once the token is changed
+	sk := sm1.ring.signingKey()
+
+	signer := jwt.NewWithClaims(sk.Method, jwt.MapClaims{"iss": sm1.issuer, "jti": "s1", "exp": time.Now().Add(time.Minute).Unix(), "testapp1.editor": "ready"})
+	signer.Header["kid"] = sk.KeyID
+
+	legacy, err := signer.SignedString(sk.SignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clm, err := sm1.GetJwtClaimElement(ctx, legacy, "testapp1.editor")
+	if err != nil || clm != "ready" {
+		t.Fatalf("flat appclaim not read: %v %v", clm, err)
+	}
+
+	migrated, err := sm1.SetAppClaim(ctx, legacy, "testapp1.viewer", "ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clms, err := sm1.GetJwtClaim(ctx, migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clms.AppClaims) != 2 || clms.AppClaims["testapp1.editor"] != "ready" {
+		t.Fatalf("unexpected app claims: %v", clms.AppClaims)
+	}
+}
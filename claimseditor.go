@@ -0,0 +1,103 @@
+package session
+
+import (
+	"golang.org/x/net/context"
+
+	lblog "github.com/lidstromberg/log"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//ClaimsEditor batches appclaim changes to a session token, which is signed once all of them are applied
+type ClaimsEditor struct {
+	clms   *SessionClaims
+	extend bool
+}
+
+//Get returns an appclaim, including any change made earlier in the batch
+func (ce *ClaimsEditor) Get(appName string) (interface{}, bool) {
+	clm, ok := ce.clms.AppClaims[appName]
+	return clm, ok
+}
+
+//Set adds or updates an appclaim, a reserved name returns a ReservedClaimError
+func (ce *ClaimsEditor) Set(appName string, appClaim interface{}) error {
+	if err := checkAppClaimName(appName); err != nil {
+		return err
+	}
+
+	if ce.clms.AppClaims == nil {
+		ce.clms.AppClaims = make(map[string]interface{})
+	}
+
+	ce.clms.AppClaims[appName] = appClaim
+
+	return nil
+}
+
+//Delete removes an appclaim, a reserved name returns a ReservedClaimError
+func (ce *ClaimsEditor) Delete(appName string) error {
+	if err := checkAppClaimName(appName); err != nil {
+		return err
+	}
+
+	delete(ce.clms.AppClaims, appName)
+
+	return nil
+}
+
+//Extend also extends the expiry by the idle timeout of the session policy, as RefreshSession does
+func (ce *ClaimsEditor) Extend() {
+	ce.extend = true
+}
+
+//ModifyClaims parses the token once, applies every change made by fn and signs the token once
+//no token is issued if fn returns an error, flat appclaims of older tokens move into the app object
+func (sessMgr *SessMgr) ModifyClaims(ctx context.Context, sessionID string, fn func(*ClaimsEditor) error) (string, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "ModifyClaims", "info", "start")
+	}
+
+	//extract the token
+	clms, err := sessMgr.extractOwnJwt(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	ce := &ClaimsEditor{clms: clms}
+
+	if err := fn(ce); err != nil {
+		return "", err
+	}
+
+	if ce.extend {
+		//access tokens can only be renewed through their refresh token
+		if clms.TokenType == ConstTokenAccess {
+			return "", ErrJwtTokenType
+		}
+
+		now := sessMgr.clock.Now()
+
+		//the extension cannot pass the absolute session lifetime
+		limit, err := sessMgr.absoluteExpiry(clms, now.Add(sessMgr.policy.IdleTimeout))
+		if err != nil {
+			return "", err
+		}
+
+		clms.ExpiresAt = jwt.NewNumericDate(limit)
+		clms.IssuedAt = jwt.NewNumericDate(now)
+		clms.NotBefore = jwt.NewNumericDate(now)
+	}
+
+	//sign the string again
+	tokenString, err := sessMgr.signClaims(clms)
+	if err != nil {
+		return "", err
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "ModifyClaims", "info", "end")
+	}
+
+	return tokenString, nil
+}
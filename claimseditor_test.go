@@ -0,0 +1,107 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+)
+
+func Test_ModifyClaims(t *testing.T) {
+	ctx := context.Background()
+
+	sp := SessionPolicy{InitialLifetime: 10 * time.Minute, IdleTimeout: 30 * time.Minute}
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithSessionPolicy(sp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err = sm1.SetAppClaim(ctx, sess, "testapp1.old", "gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newsess, err := sm1.ModifyClaims(ctx, sess, func(ce *ClaimsEditor) error {
+		if err := ce.Set("testapp1.editor", "ready"); err != nil {
+			return err
+		}
+		if err := ce.Set("testapp2.viewer", true); err != nil {
+			return err
+		}
+		if err := ce.Delete("testapp1.old"); err != nil {
+			return err
+		}
+
+		//reserved names are rejected without ending the batch
+		if err := ce.Set(ConstJwtAccID, "someoneElse"); err == nil {
+			t.Error("a session claim should not be set by the editor")
+		}
+
+		if _, ok := ce.Get("testapp1.editor"); !ok {
+			t.Error("a change should be visible within the batch")
+		}
+
+		ce.Extend()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clms, err := sm1.GetJwtClaim(ctx, newsess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if clms.AppClaims["testapp1.editor"] != "ready" || clms.AppClaims["testapp2.viewer"] != true {
+		t.Fatalf("appclaims not set: %v", clms.AppClaims)
+	}
+
+	if _, ok := clms.AppClaims["testapp1.old"]; ok {
+		t.Fatal("appclaim not deleted")
+	}
+
+	if clms.AccountID != "dummyUser1" {
+		t.Fatal("session claims should not be changed by the editor")
+	}
+
+	if _, ok := clms.AppClaims[ConstJwtAccID]; ok {
+		t.Fatal("a reserved name should not be held as an appclaim")
+	}
+
+	if d := time.Until(clms.ExpiresAt.Time); d > 30*time.Minute || d < 29*time.Minute {
+		t.Fatalf("expected the idle timeout, got %v", d)
+	}
+
+	//an error from the batch issues no token
+	errBatch := errors.New("batch failed")
+
+	if _, err := sm1.ModifyClaims(ctx, sess, func(ce *ClaimsEditor) error {
+		ce.Set("testapp1.editor", "ready")
+		return errBatch
+	}); err != errBatch {
+		t.Fatalf("expected %v, got %v", errBatch, err)
+	}
+
+	//access tokens are only extended through their refresh token
+	pair, err := sm1.NewSessionPair(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.ModifyClaims(ctx, pair.AccessToken, func(ce *ClaimsEditor) error {
+		ce.Extend()
+		return nil
+	}); err != ErrJwtTokenType {
+		t.Fatalf("expected %v, got %v", ErrJwtTokenType, err)
+	}
+}
@@ -0,0 +1,64 @@
+package session
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//Clock provides the current time used to issue and validate tokens
+type Clock interface {
+	Now() time.Time
+}
+
+//systemClock is the wall clock
+type systemClock struct{}
+
+//Now returns the current wall clock time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+//clockSetter is implemented by the key ring and in-memory stores, so they follow the clock of their manager
+type clockSetter interface {
+	setClock(c Clock)
+}
+
+//clockSource is implemented by verifiers and session managers
+type clockSource interface {
+	sessionClock() Clock
+}
+
+//sessionClock returns the clock used to issue and validate tokens
+func (verifier *Verifier) sessionClock() Clock {
+	return verifier.clock
+}
+
+//verifierClock returns the clock of the verifier, or the wall clock if it does not expose one
+func verifierClock(sv SessVerifier) Clock {
+	if cs, ok := sv.(clockSource); ok {
+		return cs.sessionClock()
+	}
+
+	return systemClock{}
+}
+
+//validTimes checks exp, nbf and iat against the clock, allowing for the leeway either side
+//tokens without these claims are not refused, as the jwt parser does not refuse them
+func (verifier *Verifier) validTimes(clms *SessionClaims) error {
+	now := verifier.clock.Now()
+
+	if clms.ExpiresAt != nil && now.After(clms.ExpiresAt.Add(verifier.leeway)) {
+		return jwt.ErrTokenExpired
+	}
+
+	if clms.NotBefore != nil && now.Add(verifier.leeway).Before(clms.NotBefore.Time) {
+		return jwt.ErrTokenNotValidYet
+	}
+
+	if clms.IssuedAt != nil && now.Add(verifier.leeway).Before(clms.IssuedAt.Time) {
+		return jwt.ErrTokenUsedBeforeIssued
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/net/context"
+)
+
+//testClock is a clock which only moves when it is advanced
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Now().Truncate(time.Second)}
+}
+func (tc *testClock) Now() time.Time {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	return tc.now
+}
+func (tc *testClock) Advance(d time.Duration) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.now = tc.now.Add(d)
+}
+func Test_Leeway(t *testing.T) {
+	ctx := context.Background()
+
+	clk := newTestClock()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithClock(clk), WithSessionPolicy(SessionPolicy{InitialLifetime: time.Minute, IdleTimeout: time.Minute}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		offset time.Duration
+		leeway time.Duration
+		err    error
+	}{
+		{"valid", 30 * time.Second, 0, nil},
+		{"expired", 61 * time.Second, 0, jwt.ErrTokenExpired},
+		{"expired within leeway", 61 * time.Second, 5 * time.Second, nil},
+		{"expired beyond leeway", 66 * time.Second, 5 * time.Second, jwt.ErrTokenExpired},
+		{"verifier clock behind", -2 * time.Second, 0, jwt.ErrTokenNotValidYet},
+		{"verifier clock behind within leeway", -2 * time.Second, 5 * time.Second, nil},
+	}
+
+	for _, tc := range cases {
+		vclk := newTestClock()
+		vclk.now = clk.Now().Add(tc.offset)
+
+		vf, err := NewVerifier(ctx, lbcf.NewConfig(ctx), sm1.ring, WithClock(vclk), WithLeeway(tc.leeway))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := vf.IsSessionValid(ctx, sess); !errors.Is(err, tc.err) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.err, err)
+		}
+	}
+
+	//the leeway is read from config unless set
+	t.Setenv("JWT_LEEWAYSEC", "5")
+
+	clk.Advance(61 * time.Second)
+
+	sm2, err := NewMgrWithKeyRing(ctx, lbcf.NewConfig(ctx), sm1.ring, WithClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm2.IsSessionValid(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewVerifier(ctx, lbcf.NewConfig(ctx), sm1.ring, WithLeeway(-time.Second)); err != ErrLeewayInvalid {
+		t.Fatalf("expected %v, got %v", ErrLeewayInvalid, err)
+	}
+}
+func Test_ClockStores(t *testing.T) {
+	ctx := context.Background()
+
+	//the manager clock is two days behind the wall clock, further than the refresh token lifetime
+	clk := newTestClock()
+	clk.Advance(-48 * time.Hour)
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//token families expire by the manager clock
+	pair, err := sm1.NewSessionPair(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.RefreshSessionPair(ctx, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	//revocations expire by the manager clock
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sm1.RevokeSession(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, sess); err != ErrJwtRevoked {
+		t.Fatalf("expected %v, got %v", ErrJwtRevoked, err)
+	}
+
+	//retired keys expire by the manager clock
+	shdr := createBaseClaims()
+	shdr.ID = "dummyUser1RetiredKey"
+
+	old, err := sm1.NewSession(ctx, shdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sm1.RotateKey(ctx, createTestKeys(t)["ES256"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Advance(sm1.ring.overlap + time.Second)
+
+	if _, err := sm1.IsSessionValid(ctx, old); err != ErrJwtKeyRetired {
+		t.Fatalf("expected %v, got %v", ErrJwtKeyRetired, err)
+	}
+
+	//the middleware threshold is measured by the manager clock
+	shdr = createBaseClaims()
+	shdr.ID = "dummyUser1Fresh"
+
+	fresh, err := sm1.NewSession(ctx, shdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := sm1.AuthMiddleware(AuthConfig{Refresh: &RefreshConfig{Threshold: time.Minute, HeaderName: "X-Session-Token"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+fresh)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Session-Token") != "" {
+		t.Fatalf("a fresh token should not be refreshed, got %d %q", rec.Code, rec.Header().Get("X-Session-Token"))
+	}
+}
+func Test_ClockSessionStore(t *testing.T) {
+	ctx := context.Background()
+
+	clk := newTestClock()
+	clk.Advance(-48 * time.Hour)
+
+	ss := NewMemSessionStore()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithClock(clk), WithSessionStore(ss))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.NewSession(ctx, createBaseClaims()); err != nil {
+		t.Fatal(err)
+	}
+
+	//login candidates are dated by the manager clock
+	lc, err := ss.GetCandidate(ctx, "dummyUser1SessId")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lc.CreatedDate == nil || !lc.CreatedDate.Equal(clk.Now()) {
+		t.Fatalf("expected created date %v, got %v", clk.Now(), lc.CreatedDate)
+	}
+
+	lc, err = ss.ActivateCandidate(ctx, "dummyUser1SessId")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lc.ActivatedDate == nil || !lc.ActivatedDate.Equal(clk.Now()) {
+		t.Fatalf("expected activated date %v, got %v", clk.Now(), lc.ActivatedDate)
+	}
+}
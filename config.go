@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	lbcf "github.com/lidstromberg/config"
 
@@ -24,6 +25,20 @@ const (
 	ConstJwtAccID = "aid"
 	//ConstJwtEml email
 	ConstJwtEml = "eml"
+	//ConstJwtAuth original authentication time, kept through every refresh
+	ConstJwtAuth = "auth_time"
+	//ConstJwtType token type, only set on access/refresh token pairs
+	ConstJwtType = "typ"
+	//ConstTokenAccess access token type
+	ConstTokenAccess = "access"
+	//ConstTokenRefresh refresh token type
+	ConstTokenRefresh = "refresh"
+	//ConstJwtFamily token family id, shared by the tokens of a refresh chain
+	ConstJwtFamily = "fam"
+	//ConstJwtGeneration refresh generation within the token family
+	ConstJwtGeneration = "gen"
+	//ConstJwtApp object which holds the appclaims
+	ConstJwtApp = "app"
 )
 
 //preflight config checks
@@ -73,8 +88,24 @@ func preflightConfigLoader() map[string]string {
 	cfm["EnvSessTokenIssuer"] = os.Getenv("JWT_ISSUER")
 	//EnvSessExtensionMin is the number of minutes by which a token is extended on each touch
 	cfm["EnvSessExtensionMin"] = os.Getenv("JWT_EXTMIN")
-	//EnvSessAppRoleDelim is the delimiter character used when joining the user app roles in the jwt
+	//EnvSessAppRoleDelim is the delimiter character of the role string in tokens issued before roles were a list
 	cfm["EnvSessAppRoleDelim"] = os.Getenv("JWT_APPROLEDELIM")
+	//EnvSessKeyOverlapMin is the number of minutes a retired signing key remains valid (optional, defaults to EnvSessExtensionMin)
+	cfm["EnvSessKeyOverlapMin"] = os.Getenv("JWT_KEYOVERLAPMIN")
+	//EnvSessAccessMin is the lifetime in minutes of a paired access token (optional, defaults to EnvSessExtensionMin)
+	cfm["EnvSessAccessMin"] = os.Getenv("JWT_ACCESSMIN")
+	//EnvSessRefreshMin is the lifetime in minutes of a paired refresh token (optional, defaults to 1440)
+	cfm["EnvSessRefreshMin"] = os.Getenv("JWT_REFRESHMIN")
+	//EnvSessMaxLifeMin is the absolute session lifetime in minutes, after which refresh is refused (optional, unlimited if not set)
+	cfm["EnvSessMaxLifeMin"] = os.Getenv("JWT_MAXLIFEMIN")
+	//EnvSessRefreshAheadMin only re-issues a token on refresh within this many minutes of expiry (optional, always re-issues if not set)
+	cfm["EnvSessRefreshAheadMin"] = os.Getenv("JWT_REFRESHAHEADMIN")
+	//EnvSessRoleHierarchy is a json object which maps each role to the roles it implies, eg {"admin":["editor"],"editor":["viewer"]} (optional)
+	cfm["EnvSessRoleHierarchy"] = os.Getenv("JWT_ROLEHIERARCHY")
+	//EnvSessAudience is a comma separated list of the audiences which tokens are issued for and which are accepted (optional, no audience if not set)
+	cfm["EnvSessAudience"] = os.Getenv("JWT_AUDIENCE")
+	//EnvSessLeewaySec is the clock skew in seconds allowed when checking exp, nbf and iat (optional, no leeway if not set)
+	cfm["EnvSessLeewaySec"] = os.Getenv("JWT_LEEWAYSEC")
 
 	if cfm["EnvDebugOn"] == "" {
 		log.Fatal("Could not parse environment variable EnvDebugOn")
@@ -94,3 +125,26 @@ func preflightConfigLoader() map[string]string {
 
 	return cfm
 }
+
+//optionalConfigList returns the comma separated values of an optional config setting, or nil if it is not set
+func optionalConfigList(ctx context.Context, bc lbcf.ConfigSetting, key string) []string {
+	var out []string
+
+	for _, val := range strings.Split(bc.GetConfigValue(ctx, key), ",") {
+		if val = strings.TrimSpace(val); val != "" {
+			out = append(out, val)
+		}
+	}
+
+	return out
+}
+
+//optionalConfigInt returns the integer value of an optional config setting, or the default if it is not set
+func optionalConfigInt(ctx context.Context, bc lbcf.ConfigSetting, key string, def int) (int, error) {
+	val := bc.GetConfigValue(ctx, key)
+	if val == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(val)
+}
@@ -0,0 +1,343 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	lblog "github.com/lidstromberg/log"
+)
+
+//maxCookieChunk is the largest value written to one cookie, browsers drop cookies over 4096 bytes including name and attributes
+const maxCookieChunk = 3800
+
+//CookieConfig configures the session cookie transport
+type CookieConfig struct {
+	//Name of the session cookie, defaults to session
+	Name string
+	//Domain of the cookies, defaults to the request host
+	Domain string
+	//Path of the cookies, defaults to /
+	Path string
+	//SameSite mode of the cookies, defaults to lax
+	SameSite http.SameSite
+	//AllowInsecure drops the Secure attribute, only for local development over http
+	AllowInsecure bool
+	//CSRFCookieName is the script-readable cookie which carries the csrf token, defaults to csrf
+	CSRFCookieName string
+	//CSRFHeaderName is the request header in which clients echo the csrf token, defaults to X-CSRF-Token
+	CSRFHeaderName string
+}
+
+//CookieTransport writes and reads session tokens as HttpOnly cookies, with a csrf token bound to the session jti
+//tokens which are too large for one cookie are split across numbered cookies (name.0, name.1, ...), each prefixed with the chunk count
+type CookieTransport struct {
+	sv     SessVerifier
+	cfg    CookieConfig
+	secret []byte
+}
+
+//NewCookieTransport creates a cookie transport for the verifier, csrf tokens are keyed with the secret
+func NewCookieTransport(sv SessVerifier, cfg CookieConfig, csrfSecret []byte) (*CookieTransport, error) {
+	if len(csrfSecret) < minHMACKeyLen {
+		return nil, ErrSigningKeyNotSupported
+	}
+
+	if cfg.Name == "" {
+		cfg.Name = "session"
+	}
+
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+
+	if cfg.CSRFCookieName == "" {
+		cfg.CSRFCookieName = "csrf"
+	}
+
+	if cfg.CSRFHeaderName == "" {
+		cfg.CSRFHeaderName = "X-CSRF-Token"
+	}
+
+	return &CookieTransport{sv: sv, cfg: cfg, secret: csrfSecret}, nil
+}
+
+//NewCookieTransport creates a cookie transport for the manager
+func (sessMgr *SessMgr) NewCookieTransport(cfg CookieConfig, csrfSecret []byte) (*CookieTransport, error) {
+	return NewCookieTransport(sessMgr, cfg, csrfSecret)
+}
+
+//WriteSession sets the session cookie and its csrf cookie, both expire with the token
+//chunks left over in the request from a larger token are expired
+func (ct *CookieTransport) WriteSession(ctx context.Context, w http.ResponseWriter, r *http.Request, token string) error {
+	//only valid tokens are written, and their claims give the cookie lifetime and csrf binding
+	clms, err := ct.sv.GetJwtClaim(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if clms.ID == "" || clms.ExpiresAt == nil {
+		return ErrClaimElementNotExist
+	}
+
+	expires := clms.ExpiresAt.Time
+
+	chunks := ct.chunks(token)
+	written := make(map[string]bool, len(chunks))
+
+	for i, value := range chunks {
+		name := ct.cfg.Name
+		if len(chunks) > 1 {
+			name = ct.chunkName(i)
+		}
+
+		written[name] = true
+		http.SetCookie(w, ct.newCookie(name, value, expires, true))
+	}
+
+	ct.expireStale(w, r, written)
+
+	//the csrf cookie is read by client script and echoed in the csrf header
+	http.SetCookie(w, ct.newCookie(ct.cfg.CSRFCookieName, ct.CSRFToken(clms.ID), expires, false))
+
+	return nil
+}
+
+//ReadSession returns the session token carried by the request cookie, or reassembled from its chunks
+func (ct *CookieTransport) ReadSession(r *http.Request) (string, error) {
+	first, err := r.Cookie(ct.chunkName(0))
+	if err != nil {
+		ck, err := r.Cookie(ct.cfg.Name)
+		if err != nil || ck.Value == "" {
+			return "", ErrJwtNotPresent
+		}
+
+		return ck.Value, nil
+	}
+
+	count, part, err := splitChunk(first.Value, -1)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(part)
+
+	for i := 1; i < count; i++ {
+		ck, err := r.Cookie(ct.chunkName(i))
+		if err != nil {
+			return "", ErrCookieChunksInvalid
+		}
+
+		_, part, err := splitChunk(ck.Value, count)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(part)
+	}
+
+	//a chunk beyond the count means the set mixes two tokens
+	if _, err := r.Cookie(ct.chunkName(count)); err == nil {
+		return "", ErrCookieChunksInvalid
+	}
+
+	return sb.String(), nil
+}
+
+//ClearSession expires the session and csrf cookies, including every session chunk carried by the request
+func (ct *CookieTransport) ClearSession(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, ct.newCookie(ct.cfg.Name, "", time.Time{}, true))
+	ct.expireStale(w, r, map[string]bool{ct.cfg.Name: true})
+	http.SetCookie(w, ct.newCookie(ct.cfg.CSRFCookieName, "", time.Time{}, false))
+}
+
+//chunks splits the token into the cookie values which carry it, a token which fits in one cookie is not chunked
+func (ct *CookieTransport) chunks(token string) []string {
+	if len(token) <= maxCookieChunk {
+		return []string{token}
+	}
+
+	//the count prefix takes room from each chunk, so grow the count until the payload fits
+	count, size := 1, 0
+	for {
+		size = maxCookieChunk - len(strconv.Itoa(count)) - 1
+		n := (len(token) + size - 1) / size
+		if n <= count {
+			break
+		}
+		count = n
+	}
+
+	prefix := strconv.Itoa(count) + ":"
+
+	out := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(token) {
+			end = len(token)
+		}
+		out = append(out, prefix+token[i*size:end])
+	}
+
+	return out
+}
+
+//expireStale expires the session cookies in the request which are not in the written set
+func (ct *CookieTransport) expireStale(w http.ResponseWriter, r *http.Request, written map[string]bool) {
+	if r == nil {
+		return
+	}
+
+	for _, ck := range r.Cookies() {
+		if written[ck.Name] || !ct.isSessionCookie(ck.Name) {
+			continue
+		}
+
+		http.SetCookie(w, ct.newCookie(ck.Name, "", time.Time{}, true))
+	}
+}
+
+//isSessionCookie reports whether the cookie name is the session cookie or one of its chunks
+func (ct *CookieTransport) isSessionCookie(name string) bool {
+	if name == ct.cfg.Name {
+		return true
+	}
+
+	idx, ok := strings.CutPrefix(name, ct.cfg.Name+".")
+	if !ok {
+		return false
+	}
+
+	_, err := strconv.Atoi(idx)
+
+	return err == nil
+}
+
+//chunkName returns the cookie name of the chunk at idx
+func (ct *CookieTransport) chunkName(idx int) string {
+	return ct.cfg.Name + "." + strconv.Itoa(idx)
+}
+
+//splitChunk separates the chunk count from the chunk payload, checking the count against the expected one unless it is negative
+func splitChunk(value string, expected int) (int, string, error) {
+	cnt, part, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, "", ErrCookieChunksInvalid
+	}
+
+	count, err := strconv.Atoi(cnt)
+	if err != nil || count < 1 || (expected >= 0 && count != expected) {
+		return 0, "", ErrCookieChunksInvalid
+	}
+
+	return count, part, nil
+}
+
+//CheckCSRF checks that a request with an unsafe method echoes the csrf token of its session
+//requests which authenticate with a bearer token, or carry no session cookie, are not exposed to csrf and are not checked
+//browsers attach cached Basic and Negotiate credentials on their own, so those headers do not skip the check
+func (ct *CookieTransport) CheckCSRF(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+
+	if _, ok := bearerToken(r.Header.Get("Authorization")); ok || !ct.hasSessionCookie(r) {
+		return nil
+	}
+
+	token, err := ct.ReadSession(r)
+	if err != nil {
+		return err
+	}
+
+	clms, err := ct.sv.GetJwtClaim(r.Context(), token)
+	if err != nil {
+		return err
+	}
+
+	if clms.ID == "" {
+		return ErrClaimElementNotExist
+	}
+
+	echoed := r.Header.Get(ct.cfg.CSRFHeaderName)
+	if echoed == "" || !hmac.Equal([]byte(echoed), []byte(ct.CSRFToken(clms.ID))) {
+		return ErrCSRFTokenInvalid
+	}
+
+	return nil
+}
+
+//hasSessionCookie returns true if the request carries the session cookie or any of its chunks
+func (ct *CookieTransport) hasSessionCookie(r *http.Request) bool {
+	for _, ck := range r.Cookies() {
+		if ct.isSessionCookie(ck.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//CSRFMiddleware returns middleware which rejects unsafe requests without a valid csrf token
+func (ct *CookieTransport) CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := ct.CheckCSRF(r); err != nil {
+			ct.logError("CSRFMiddleware", err)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+//newCookie returns a cookie with the configured attributes
+func (ct *CookieTransport) newCookie(name, value string, exp time.Time, httpOnly bool) *http.Cookie {
+	ck := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   ct.cfg.Domain,
+		Path:     ct.cfg.Path,
+		Secure:   !ct.cfg.AllowInsecure,
+		HttpOnly: httpOnly,
+		SameSite: ct.cfg.SameSite,
+	}
+
+	if exp.IsZero() {
+		//an expired cookie is removed by the browser
+		ck.MaxAge = -1
+		return ck
+	}
+
+	ck.Expires = exp
+	ck.MaxAge = int(exp.Sub(verifierClock(ct.sv).Now()).Seconds())
+
+	return ck
+}
+
+//CSRFToken returns the csrf token bound to the session id
+func (ct *CookieTransport) CSRFToken(sessionID string) string {
+	mac := hmac.New(sha256.New, ct.secret)
+	mac.Write([]byte(sessionID))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//logError logs cookie transport failures when debugging
+func (ct *CookieTransport) logError(fn string, err error) {
+	if EnvDebugOn {
+		lblog.LogEvent("CookieTransport", fn, "info", err.Error())
+	}
+}
@@ -0,0 +1,354 @@
+package session
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+)
+
+func Test_CookieTransport(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.NewCookieTransport(CookieConfig{}, []byte("short")); err != ErrSigningKeyNotSupported {
+		t.Fatalf("short csrf secret: expected %v, got %v", ErrSigningKeyNotSupported, err)
+	}
+
+	ct, err := sm1.NewCookieTransport(CookieConfig{}, bytes.Repeat([]byte("s"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ct.WriteSession(ctx, httptest.NewRecorder(), nil, sess+"x"); err == nil {
+		t.Fatal("an invalid token was written to a cookie")
+	}
+
+	rec := httptest.NewRecorder()
+	if err := ct.WriteSession(ctx, rec, nil, sess); err != nil {
+		t.Fatal(err)
+	}
+
+	cks := rec.Result().Cookies()
+	if len(cks) != 2 {
+		t.Fatalf("expected 2 cookies, got %d", len(cks))
+	}
+
+	sessck, csrfck := cks[0], cks[1]
+	if sessck.Name != "session" || !sessck.HttpOnly || !sessck.Secure || sessck.SameSite != http.SameSiteLaxMode || sessck.MaxAge <= 0 {
+		t.Fatalf("session cookie attributes not set: %+v", sessck)
+	}
+	if csrfck.Name != "csrf" || csrfck.HttpOnly {
+		t.Fatalf("csrf cookie must be readable by script: %+v", csrfck)
+	}
+
+	jti, err := sm1.GetJwtClaimElement(ctx, sess, ConstJwtID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csrfck.Value != ct.CSRFToken(jti.(string)) {
+		t.Fatal("csrf cookie is not bound to the session id")
+	}
+
+	var reached bool
+	h := ct.CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	cases := []struct {
+		name   string
+		method string
+		csrf   string
+		status int
+	}{
+		{"safe method", http.MethodGet, "", http.StatusOK},
+		{"unsafe with token", http.MethodPost, csrfck.Value, http.StatusOK},
+		{"unsafe without token", http.MethodPost, "", http.StatusForbidden},
+		{"unsafe with other token", http.MethodDelete, ct.CSRFToken("other"), http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		reached = false
+
+		req := httptest.NewRequest(tc.method, "/", nil)
+		req.AddCookie(sessck)
+		if tc.csrf != "" {
+			req.Header.Set("X-CSRF-Token", tc.csrf)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tc.status {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.status, rec.Code)
+		}
+		if reached != (tc.status == http.StatusOK) {
+			t.Fatalf("%s: handler reached %v", tc.name, reached)
+		}
+	}
+
+	//requests which are not authenticated by the session cookie are not exposed to csrf
+	for _, hdr := range []string{"Bearer " + sess, ""} {
+		reached = false
+
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if hdr != "" {
+			req.Header.Set("Authorization", hdr)
+			req.AddCookie(sessck)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || !reached {
+			t.Fatalf("header %q: expected the csrf check to be skipped, got %d", hdr, rec.Code)
+		}
+	}
+
+	//credentials the browser attaches on its own do not skip the check
+	basic := httptest.NewRequest(http.MethodPost, "/", nil)
+	basic.SetBasicAuth("user", "pass")
+	basic.AddCookie(sessck)
+
+	if err := ct.CheckCSRF(basic); err != ErrCSRFTokenInvalid {
+		t.Fatalf("basic credentials: expected %v, got %v", ErrCSRFTokenInvalid, err)
+	}
+
+	//the authentication middleware reads the session from the transport
+	var found *Session
+	auth := sm1.AuthMiddleware(AuthConfig{Cookies: ct})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		found, _ = SessionFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(sessck)
+	auth.ServeHTTP(httptest.NewRecorder(), req)
+
+	if found == nil || found.Token != sess {
+		t.Fatal("session was not read from the cookie transport")
+	}
+
+	rec = httptest.NewRecorder()
+	ct.ClearSession(rec, req)
+
+	for _, ck := range rec.Result().Cookies() {
+		if ck.MaxAge >= 0 || ck.Value != "" {
+			t.Fatalf("cookie %s was not expired", ck.Name)
+		}
+	}
+}
+
+func Test_CookieChunks(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ct, err := sm1.NewCookieTransport(CookieConfig{}, bytes.Repeat([]byte("s"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	small, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large, err := sm1.SetAppClaim(ctx, small, "testapp1.profile", strings.Repeat("p", 3*maxCookieChunk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//write the large token, every cookie must fit in the browser limit
+	rec := httptest.NewRecorder()
+	if err := ct.WriteSession(ctx, rec, nil, large); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	var chunks []*http.Cookie
+
+	for _, ck := range rec.Result().Cookies() {
+		if len(ck.String()) > 4096 {
+			t.Fatalf("cookie %s is %d bytes", ck.Name, len(ck.String()))
+		}
+		if ck.Name != "csrf" {
+			chunks = append(chunks, ck)
+		}
+		req.AddCookie(ck)
+	}
+
+	if len(chunks) < 3 {
+		t.Fatalf("expected the token to be chunked, got %d cookies", len(chunks))
+	}
+
+	token, err := ct.ReadSession(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != large {
+		t.Fatal("reassembled token does not match")
+	}
+
+	//shrinking the token expires the stale chunks
+	rec = httptest.NewRecorder()
+	if err := ct.WriteSession(ctx, rec, req, small); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := 0
+	next := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for _, ck := range rec.Result().Cookies() {
+		if ck.MaxAge < 0 {
+			expired++
+			continue
+		}
+		next.AddCookie(ck)
+	}
+
+	if expired != len(chunks) {
+		t.Fatalf("expected %d stale chunks to be expired, got %d", len(chunks), expired)
+	}
+
+	token, err = ct.ReadSession(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != small {
+		t.Fatal("shrunk token does not match")
+	}
+
+	//inconsistent chunk sets are rejected
+	cases := []struct {
+		name    string
+		cookies []*http.Cookie
+	}{
+		{"missing chunk", []*http.Cookie{chunks[0], chunks[2]}},
+		{"mixed counts", []*http.Cookie{chunks[0], {Name: "session.1", Value: "2:abc"}, chunks[2]}},
+		{"extra chunk", append(append([]*http.Cookie{}, chunks...), &http.Cookie{Name: "session." + strconv.Itoa(len(chunks)), Value: "x"})},
+		{"no count", []*http.Cookie{{Name: "session.0", Value: "abc"}}},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, ck := range tc.cookies {
+			req.AddCookie(ck)
+		}
+
+		if _, err := ct.ReadSession(req); err != ErrCookieChunksInvalid {
+			t.Fatalf("%s: expected %v, got %v", tc.name, ErrCookieChunksInvalid, err)
+		}
+	}
+}
+func Test_CookieChunksRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	sp := SessionPolicy{InitialLifetime: 10 * time.Minute, IdleTimeout: 30 * time.Minute}
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithSessionPolicy(sp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ct, err := sm1.NewCookieTransport(CookieConfig{}, bytes.Repeat([]byte("s"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large, err := sm1.SetAppClaim(ctx, sess, "testapp1.profile", strings.Repeat("p", 3*maxCookieChunk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := ct.WriteSession(ctx, rec, nil, large); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, ck := range rec.Result().Cookies() {
+		req.AddCookie(ck)
+	}
+
+	//the sliding refresh writes the new token through the transport
+	handler := sm1.AuthMiddleware(AuthConfig{Cookies: ct, Refresh: &RefreshConfig{Threshold: 15 * time.Minute, HeaderName: "X-Session-Token"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	next := httptest.NewRequest(http.MethodGet, "/", nil)
+	chunks := 0
+
+	for _, ck := range rec.Result().Cookies() {
+		if len(ck.String()) > 4096 {
+			t.Fatalf("cookie %s is %d bytes", ck.Name, len(ck.String()))
+		}
+		if ck.MaxAge < 0 {
+			continue
+		}
+		if ct.isSessionCookie(ck.Name) {
+			chunks++
+		}
+		next.AddCookie(ck)
+	}
+
+	if chunks < 3 {
+		t.Fatalf("expected the refreshed token to be chunked, got %d cookies", chunks)
+	}
+
+	token, err := ct.ReadSession(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token == large {
+		t.Fatal("session was not refreshed")
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	//bearer clients get the refreshed token in the header, but no session cookies
+	bearer := httptest.NewRequest(http.MethodGet, "/", nil)
+	bearer.Header.Set("Authorization", "Bearer "+large)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, bearer)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Session-Token") == "" {
+		t.Fatalf("expected the refreshed token in the header, got %d", rec.Code)
+	}
+
+	if cks := rec.Result().Cookies(); len(cks) != 0 {
+		t.Fatalf("expected no cookies for a bearer request, got %d", len(cks))
+	}
+}
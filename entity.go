@@ -3,6 +3,7 @@ package session
 import "time"
 
 //LoginCandidate is a record of a login attempt
+//the RoleToken holds the session roles as a json list, eg ["billing:read","testapp1"]
 type LoginCandidate struct {
 	SessionID     string     `json:"sessionid" datastore:"sessionid"`
 	UserAccountID string     `json:"useraccountid" datastore:"useraccountid"`
@@ -12,3 +13,9 @@ type LoginCandidate struct {
 	CreatedDate   *time.Time `json:"createddate,omitempty" datastore:"createddate"`
 	ActivatedDate *time.Time `json:"activateddate,omitempty" datastore:"activateddate"`
 }
+
+//TokenPair is a short-lived access token and the long-lived refresh token used to renew it
+type TokenPair struct {
+	AccessToken  string `json:"accesstoken"`
+	RefreshToken string `json:"refreshtoken"`
+}
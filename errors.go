@@ -14,4 +14,62 @@ var (
 	ErrJwtInvalidSession = errors.New("session is no longer valid, please login")
 	//ErrClaimElementNotExist error message
 	ErrClaimElementNotExist = errors.New("the claim element does not exist")
+	//ErrSigningKeyNotSupported occurs if a signing key has an unsupported type or size
+	ErrSigningKeyNotSupported = errors.New("signing key is not supported")
+	//ErrJwtAlgNotAllowed occurs if a signing algorithm is unknown or not on the allowlist
+	ErrJwtAlgNotAllowed = errors.New("signing algorithm is not allowed")
+	//ErrKeyIDNotExist occurs if a key id is not held in the key ring
+	ErrKeyIDNotExist = errors.New("the key id does not exist")
+	//ErrKeyIDExists occurs if a key id is added to a key ring twice
+	ErrKeyIDExists = errors.New("the key id already exists")
+	//ErrKeyIDActive occurs if the active signing key is revoked before it is rotated out
+	ErrKeyIDActive = errors.New("the active key cannot be revoked")
+	//ErrJwtKeyRevoked occurs if a token was signed with a revoked key
+	ErrJwtKeyRevoked = errors.New("token signing key has been revoked, please login")
+	//ErrJwtKeyRetired occurs if a token was signed with a key which is past its overlap window
+	ErrJwtKeyRetired = errors.New("token signing key has been retired, please login")
+	//ErrJwtRevoked occurs if a session has been revoked
+	ErrJwtRevoked = errors.New("session has been revoked, please login")
+	//ErrLoginCandidateInvalid occurs if a login candidate has no session id
+	ErrLoginCandidateInvalid = errors.New("login candidate requires a session id")
+	//ErrTableNameInvalid occurs if a store table name is not a plain identifier
+	ErrTableNameInvalid = errors.New("table name must be a plain identifier")
+	//ErrLoginCandidateExists occurs if a login candidate is created twice
+	ErrLoginCandidateExists = errors.New("login candidate already exists")
+	//ErrLoginCandidateNotExist occurs if a login candidate cannot be found
+	ErrLoginCandidateNotExist = errors.New("login candidate does not exist")
+	//ErrJwtTokenType occurs if an access token is used as a refresh token, or the reverse
+	ErrJwtTokenType = errors.New("token type is not valid for this operation")
+	//ErrJwtRefreshReused occurs if a refresh token is presented after it has been rotated, the token family is revoked
+	ErrJwtRefreshReused = errors.New("refresh token has already been used, please login")
+	//ErrJwtSessionExpired occurs if a session is refreshed after its absolute lifetime
+	ErrJwtSessionExpired = errors.New("session has reached its maximum lifetime, please login")
+	//ErrSessionPolicyInvalid occurs if a session policy has unusable lifetimes
+	ErrSessionPolicyInvalid = errors.New("session policy lifetimes are not valid")
+	//ErrJwtNotPresent occurs if a request does not carry a session token
+	ErrJwtNotPresent = errors.New("no session token was presented")
+	//ErrRoleNotHeld occurs if a session does not hold a required role
+	ErrRoleNotHeld = errors.New("session does not hold the required role")
+	//ErrCSRFTokenInvalid occurs if an unsafe request does not carry the csrf token of its session
+	ErrCSRFTokenInvalid = errors.New("csrf token is missing or not valid")
+	//ErrCookieChunksInvalid occurs if the session cookie chunks are missing, mixed or malformed
+	ErrCookieChunksInvalid = errors.New("the session cookie chunks are not consistent")
+	//ErrAppClaimType occurs if an appclaim cannot be encoded, or decoded into the requested type
+	ErrAppClaimType = errors.New("the appclaim does not have the requested type")
+	//ErrRoleHierarchyInvalid occurs if the role hierarchy cannot be parsed or a role implies itself
+	ErrRoleHierarchyInvalid = errors.New("the role hierarchy is not valid")
+	//ErrPolicyNotSet occurs if Authorize is called without a policy evaluator
+	ErrPolicyNotSet = errors.New("no policy evaluator is set")
+	//ErrPolicyRuleInvalid occurs if a policy rule has an unknown effect, operator or attribute, or a bad pattern
+	ErrPolicyRuleInvalid = errors.New("the policy rule is not valid")
+	//ErrJwtAudience occurs if a token is not issued for an accepted audience
+	ErrJwtAudience = errors.New("the jwt is not issued for this audience")
+	//ErrJwtIssuer occurs if a token is not issued by this issuer or a trusted issuer, or a trusted issuer is not valid
+	ErrJwtIssuer = errors.New("the jwt issuer is not trusted")
+	//ErrLeewayInvalid occurs if the clock skew leeway is negative
+	ErrLeewayInvalid = errors.New("the clock skew leeway cannot be negative")
+	//ErrTokenFamilyExists occurs if a token family is created twice
+	ErrTokenFamilyExists = errors.New("token family already exists")
+	//ErrTokenFamilyNotExist occurs if a token family is unknown or has expired
+	ErrTokenFamilyNotExist = errors.New("token family does not exist, please login")
 )
@@ -0,0 +1,114 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+//FamilyStore tracks the current refresh token generation of each token family
+type FamilyStore interface {
+	CreateFamily(ctx context.Context, fam string, exp time.Time) error
+	AdvanceFamily(ctx context.Context, fam string, gen int64, exp time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, fam string) error
+	IsFamilyRevoked(ctx context.Context, fam string) (bool, error)
+}
+
+//family is the rotation state of a token family
+type family struct {
+	gen     int64
+	exp     time.Time
+	revoked bool
+}
+
+//MemFamilyStore is an in-memory FamilyStore
+type MemFamilyStore struct {
+	mux      sync.Mutex
+	families map[string]*family
+	clock    Clock
+}
+
+//NewMemFamilyStore creates an empty in-memory family store
+func NewMemFamilyStore() *MemFamilyStore {
+	return &MemFamilyStore{families: make(map[string]*family), clock: systemClock{}}
+}
+
+//setClock sets the clock used to expire families
+func (fs *MemFamilyStore) setClock(c Clock) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	fs.clock = c
+}
+
+//CreateFamily starts a token family at generation zero, tracked until exp
+func (fs *MemFamilyStore) CreateFamily(ctx context.Context, fam string, exp time.Time) error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	now := fs.clock.Now()
+
+	//drop families whose last refresh token has expired
+	for id, f := range fs.families {
+		if now.After(f.exp) {
+			delete(fs.families, id)
+		}
+	}
+
+	if _, ok := fs.families[fam]; ok {
+		return ErrTokenFamilyExists
+	}
+
+	fs.families[fam] = &family{exp: exp}
+
+	return nil
+}
+
+//AdvanceFamily moves the family on from generation gen, it returns false if gen is not the current generation
+func (fs *MemFamilyStore) AdvanceFamily(ctx context.Context, fam string, gen int64, exp time.Time) (bool, error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	f, ok := fs.families[fam]
+	if !ok || fs.clock.Now().After(f.exp) {
+		return false, ErrTokenFamilyNotExist
+	}
+
+	if f.revoked || f.gen != gen {
+		return false, nil
+	}
+
+	f.gen++
+	f.exp = exp
+
+	return true, nil
+}
+
+//RevokeFamily invalidates every token of the family
+func (fs *MemFamilyStore) RevokeFamily(ctx context.Context, fam string) error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	f, ok := fs.families[fam]
+	if !ok {
+		return ErrTokenFamilyNotExist
+	}
+
+	f.revoked = true
+
+	return nil
+}
+
+//IsFamilyRevoked returns true if the family has been revoked
+func (fs *MemFamilyStore) IsFamilyRevoked(ctx context.Context, fam string) (bool, error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	f, ok := fs.families[fam]
+	if !ok {
+		return false, nil
+	}
+
+	return f.revoked, nil
+}
@@ -0,0 +1,114 @@
+package session
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+
+	lblog "github.com/lidstromberg/log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//GRPCConfig configures the grpc server interceptors
+type GRPCConfig struct {
+	//MetadataKey is the incoming metadata key which carries the token, defaults to authorization
+	MetadataKey string
+	//MethodRoles maps full method names (/package.Service/Method) to the roles the session must hold
+	MethodRoles map[string][]string
+	//PublicMethods are full method names which can be called without a session
+	PublicMethods map[string]bool
+}
+
+//UnaryServerInterceptor returns a grpc interceptor which validates the session of each unary call
+func UnaryServerInterceptor(sv SessVerifier, cfg GRPCConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if cfg.PublicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		sctx, err := cfg.authenticate(ctx, sv, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(sctx, req)
+	}
+}
+
+//StreamServerInterceptor returns a grpc interceptor which validates the session of each stream
+func StreamServerInterceptor(sv SessVerifier, cfg GRPCConfig) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if cfg.PublicMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+
+		sctx, err := cfg.authenticate(ss.Context(), sv, info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &sessionStream{ServerStream: ss, ctx: sctx})
+	}
+}
+
+//sessionStream is a server stream whose context carries the verified session
+type sessionStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+//Context returns the stream context with the session attached
+func (ss *sessionStream) Context() context.Context {
+	return ss.ctx
+}
+
+//authenticate validates the token in the incoming metadata and checks the method roles
+func (cfg GRPCConfig) authenticate(ctx context.Context, sv SessVerifier, method string) (context.Context, error) {
+	token := metadataToken(ctx, cfg.MetadataKey)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, ErrJwtNotPresent.Error())
+	}
+
+	//reading the claims validates the token
+	clms, err := sv.GetJwtClaim(ctx, token)
+	if err != nil {
+		if EnvDebugOn {
+			lblog.LogEvent("GRPCInterceptor", "authenticate", "info", err.Error())
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	//the roles are checked on the claims which have just been verified
+	if roles := cfg.MethodRoles[method]; len(roles) > 0 && !sv.HoldsAllRoles(clms, roles...) {
+		return nil, status.Error(codes.PermissionDenied, ErrRoleNotHeld.Error())
+	}
+
+	return NewSessionContext(ctx, &Session{Token: token, Claims: clms}), nil
+}
+
+//metadataToken reads the token from the incoming metadata, with or without a bearer prefix
+func metadataToken(ctx context.Context, key string) string {
+	if key == "" {
+		key = "authorization"
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	vals := md.Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+
+	if scheme, token, ok := strings.Cut(vals[0], " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return strings.TrimSpace(vals[0])
+}
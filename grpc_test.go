@@ -0,0 +1,148 @@
+package session
+
+import (
+	"net"
+	"testing"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//sessionHealthServer only reports serving if the call context carries a session
+type sessionHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (hs *sessionHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if sess, ok := SessionFromContext(ctx); !ok || sess.AccountID() != "dummyUser1" {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+func (hs *sessionHealthServer) Watch(req *healthpb.HealthCheckRequest, ws healthpb.Health_WatchServer) error {
+	resp, err := hs.Check(ws.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return ws.Send(resp)
+}
+func createGRPCClient(t *testing.T, sv SessVerifier, cfg GRPCConfig) healthpb.HealthClient {
+	lis := bufconn.Listen(1 << 20)
+
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(sv, cfg)),
+		grpc.StreamInterceptor(StreamServerInterceptor(sv, cfg)),
+	)
+	healthpb.RegisterHealthServer(srv, &sessionHealthServer{})
+
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+func Test_GRPCInterceptors(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := GRPCConfig{
+		MethodRoles: map[string][]string{"/grpc.health.v1.Health/Watch": {"testapp3"}},
+	}
+
+	client := createGRPCClient(t, sm1, cfg)
+
+	//no token
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated, got %v", err)
+	}
+
+	//invalid token
+	bad := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+sess+"x")
+	if _, err := client.Check(bad, &healthpb.HealthCheckRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated, got %v", err)
+	}
+
+	good := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+sess)
+
+	resp, err := client.Check(good, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatal("session was not attached to the call context")
+	}
+
+	//the stream requires a role the session does not hold
+	stream, err := client.Watch(good, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	cfg.MethodRoles["/grpc.health.v1.Health/Watch"] = []string{"testapp1"}
+	client = createGRPCClient(t, sm1, cfg)
+
+	stream, err = client.Watch(good, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatal("session was not attached to the stream context")
+	}
+
+	//a valid session without roles is denied, not unauthenticated
+	shdr := createBaseClaims()
+	shdr.ID = "dummyUser1NoRoles"
+	shdr.Roles = nil
+
+	bare, err := sm1.NewSession(ctx, shdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err = client.Watch(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+bare), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+}
@@ -0,0 +1,452 @@
+package session
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	lblog "github.com/lidstromberg/log"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//JSONWebKey is a public verification key in jwk format (RFC 7517)
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+//JSONWebKeySet is a set of public verification keys in jwks format
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+//newJSONWebKey converts a signing key into a jwk, symmetric keys are not published
+func newJSONWebKey(sk *SigningKey) (*JSONWebKey, bool) {
+	jwk := &JSONWebKey{
+		Kid: sk.KeyID,
+		Use: "sig",
+		Alg: sk.Alg(),
+	}
+
+	switch vk := sk.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeJwkInt(vk.N, 0)
+		jwk.E = encodeJwkInt(big.NewInt(int64(vk.E)), 0)
+	case *ecdsa.PublicKey:
+		size := (vk.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = vk.Curve.Params().Name
+		jwk.X = encodeJwkInt(vk.X, size)
+		jwk.Y = encodeJwkInt(vk.Y, size)
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(vk)
+	default:
+		return nil, false
+	}
+
+	return jwk, true
+}
+
+//encodeJwkInt base64url encodes a big-endian integer, left padded to size bytes
+func encodeJwkInt(v *big.Int, size int) string {
+	b := v.Bytes()
+
+	if len(b) < size {
+		pad := make([]byte, size-len(b))
+		b = append(pad, b...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//publicKeys returns the jwks for the keys which are still accepted for verification
+func (kr *KeyRing) publicKeys() *JSONWebKeySet {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+
+	set := &JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	//the active key is listed first
+	kids := []string{kr.active}
+	for kid := range kr.keys {
+		if kid != kr.active {
+			kids = append(kids, kid)
+		}
+	}
+
+	for _, kid := range kids {
+		rk := kr.keys[kid]
+
+		if rk.revoked || (rk.retiredAt != nil && kr.clock.Now().After(rk.retiredAt.Add(kr.overlap))) {
+			continue
+		}
+
+		if jwk, ok := newJSONWebKey(rk.key); ok {
+			set.Keys = append(set.Keys, *jwk)
+		}
+	}
+
+	return set
+}
+
+//JWKS returns the public verification keys of the manager as a json web key set
+func (sessMgr *SessMgr) JWKS(ctx context.Context) *JSONWebKeySet {
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "JWKS", "info", "start")
+	}
+
+	set := sessMgr.ring.publicKeys()
+
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "JWKS", "info", "end")
+	}
+
+	return set
+}
+
+//JWKSHandler returns an http.Handler which serves the manager jwks, for example at /.well-known/jwks.json
+func (sessMgr *SessMgr) JWKSHandler(maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := json.Marshal(sessMgr.JWKS(r.Context()))
+		if err != nil {
+			lblog.LogEvent("SessMgr", "JWKSHandler", "error", err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		etag := fmt.Sprintf(`"%s"`, base64.RawURLEncoding.EncodeToString(sum[:16]))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+		w.Header().Set("ETag", etag)
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Write(body)
+	})
+}
+
+//StaticKeySet is a fixed set of verification keys, usually parsed from a jwks document
+type StaticKeySet struct {
+	keys map[string]*SigningKey
+}
+
+//ParseJWKS parses a jwks document into a key set
+//keys which cannot be used to verify tokens, such as an unsupported curve or key type, are skipped
+func ParseJWKS(data []byte) (*StaticKeySet, error) {
+	var set JSONWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	ks := &StaticKeySet{keys: make(map[string]*SigningKey)}
+
+	//the first skipped key gives the error if no key is usable
+	var skipped error
+
+	for _, jwk := range set.Keys {
+		//keys intended for encryption are not used to verify tokens
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		sk, err := jwk.verificationKey()
+		if err == nil {
+			_, err = keyID(sk)
+		}
+
+		if err != nil {
+			if EnvDebugOn {
+				lblog.LogEvent("StaticKeySet", "ParseJWKS", "info", "skipped key "+jwk.Kid+": "+err.Error())
+			}
+
+			if skipped == nil {
+				skipped = err
+			}
+			continue
+		}
+
+		ks.keys[sk.KeyID] = sk
+	}
+
+	if len(ks.keys) == 0 {
+		if skipped != nil {
+			return nil, skipped
+		}
+
+		return nil, ErrKeyPairNotExist
+	}
+
+	return ks, nil
+}
+
+//verificationKey converts a jwk into a verify-only signing key
+func (jwk *JSONWebKey) verificationKey() (*SigningKey, error) {
+	sk := &SigningKey{KeyID: jwk.Kid}
+	alg := jwk.Alg
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJwkInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJwkInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, ErrSigningKeyNotSupported
+		}
+		sk.VerifyKey = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		if alg == "" {
+			alg = jwt.SigningMethodRS256.Alg()
+		}
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+			if alg == "" {
+				alg = jwt.SigningMethodES256.Alg()
+			}
+		case "P-384":
+			curve = elliptic.P384()
+			if alg == "" {
+				alg = jwt.SigningMethodES384.Alg()
+			}
+		default:
+			return nil, ErrSigningKeyNotSupported
+		}
+		x, err := decodeJwkInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJwkInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, ErrSigningKeyNotSupported
+		}
+		sk.VerifyKey = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrSigningKeyNotSupported
+		}
+		sk.VerifyKey = ed25519.PublicKey(x)
+		if alg == "" {
+			alg = jwt.SigningMethodEdDSA.Alg()
+		}
+	default:
+		return nil, ErrSigningKeyNotSupported
+	}
+
+	sk.Method = jwt.GetSigningMethod(alg)
+	if sk.Method == nil || sk.Method == jwt.SigningMethodNone {
+		return nil, ErrJwtAlgNotAllowed
+	}
+
+	return sk, nil
+}
+
+//decodeJwkInt decodes a base64url encoded big-endian integer
+func decodeJwkInt(v string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, ErrSigningKeyNotSupported
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+//Algs returns the algorithms of the keys in the set
+func (ks *StaticKeySet) Algs() []string {
+	var algs []string
+	seen := make(map[string]bool)
+
+	for _, sk := range ks.keys {
+		if !seen[sk.Alg()] {
+			seen[sk.Alg()] = true
+			algs = append(algs, sk.Alg())
+		}
+	}
+
+	return algs
+}
+
+//VerificationKey returns the key used to verify a token with the key id and algorithm
+func (ks *StaticKeySet) VerificationKey(kid, alg string) (interface{}, error) {
+	sk, ok := ks.keys[kid]
+
+	//a token without a key id can only be matched if the set holds a single key
+	if !ok && kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			sk, ok = k, true
+		}
+	}
+
+	if !ok {
+		return nil, ErrKeyIDNotExist
+	}
+
+	if sk.Alg() != alg {
+		return nil, ErrJwtAlgNotAllowed
+	}
+
+	return sk.VerifyKey, nil
+}
+
+//RemoteKeySet is a key set fetched from a jwks url and cached for the refresh interval
+type RemoteKeySet struct {
+	url       string
+	refresh   time.Duration
+	client    *http.Client
+	mux       sync.Mutex
+	keys      *StaticKeySet
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+//minJWKSRefetch is the minimum time between fetches triggered by unknown key ids or failed fetches
+const minJWKSRefetch = time.Minute
+
+//NewRemoteKeySet creates a key set which fetches its keys from the jwks url
+func NewRemoteKeySet(url string, refresh time.Duration) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+//load fetches and parses the jwks document
+func (rks *RemoteKeySet) load(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodGet, rks.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := rks.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not fetch jwks from %s: %s", rks.url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	rks.mux.Lock()
+	rks.keys = keys
+	rks.fetchedAt = time.Now()
+	rks.mux.Unlock()
+
+	return nil
+}
+
+//current returns the cached key set, refetching it if it is stale or if force is set and the rate limit allows
+func (rks *RemoteKeySet) current(force bool) *StaticKeySet {
+	rks.mux.Lock()
+	keys, age := rks.keys, time.Since(rks.fetchedAt)
+	stale := keys == nil || age > rks.refresh || (force && age > minJWKSRefetch)
+	if stale && keys != nil && time.Since(rks.triedAt) < minJWKSRefetch {
+		stale = false
+	}
+	if stale {
+		rks.triedAt = time.Now()
+	}
+	rks.mux.Unlock()
+
+	if stale {
+		//keep serving the cached keys if the jwks endpoint is unavailable
+		if err := rks.load(context.Background()); err != nil {
+			lblog.LogEvent("RemoteKeySet", "current", "error", err.Error())
+		}
+
+		rks.mux.Lock()
+		keys = rks.keys
+		rks.mux.Unlock()
+	}
+
+	return keys
+}
+
+//Algs returns the algorithms of the keys in the set
+func (rks *RemoteKeySet) Algs() []string {
+	keys := rks.current(false)
+	if keys == nil {
+		return nil
+	}
+
+	return keys.Algs()
+}
+
+//VerificationKey returns the key used to verify a token with the key id and algorithm
+func (rks *RemoteKeySet) VerificationKey(kid, alg string) (interface{}, error) {
+	keys := rks.current(false)
+	if keys == nil {
+		return nil, ErrKeyPairNotExist
+	}
+
+	vk, err := keys.VerificationKey(kid, alg)
+	if err != ErrKeyIDNotExist {
+		return vk, err
+	}
+
+	//an unknown key id may mean the issuer has rotated its keys
+	keys = rks.current(true)
+
+	return keys.VerificationKey(kid, alg)
+}
@@ -0,0 +1,165 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+)
+
+func Test_JWKS(t *testing.T) {
+	ctx := context.Background()
+
+	keys := createTestKeys(t)
+
+	ring, err := NewKeyRing(keys["PS256"], time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sm1, err := NewMgrWithKeyRing(ctx, lbcf.NewConfig(ctx), ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sm1.RotateKey(ctx, keys["ES256"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sm1.RotateKey(ctx, keys["EdDSA"]); err != nil {
+		t.Fatal(err)
+	}
+
+	//symmetric keys must never be published
+	if err := sm1.RotateKey(ctx, keys["HS256"]); err != nil {
+		t.Fatal(err)
+	}
+
+	set := sm1.JWKS(ctx)
+
+	if len(set.Keys) != 3 {
+		t.Fatalf("expected 3 published keys, got %d", len(set.Keys))
+	}
+
+	kty := make(map[string]JSONWebKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kid == "" || jwk.Use != "sig" {
+			t.Fatalf("key %v is missing kid or use", jwk)
+		}
+		kty[jwk.Kty] = jwk
+	}
+
+	if kty["RSA"].Alg != "PS256" || kty["RSA"].N == "" || kty["RSA"].E != "AQAB" {
+		t.Fatalf("unexpected RSA key: %v", kty["RSA"])
+	}
+
+	if kty["EC"].Crv != "P-256" || len(kty["EC"].X) != 43 || len(kty["EC"].Y) != 43 {
+		t.Fatalf("unexpected EC key: %v", kty["EC"])
+	}
+
+	if kty["OKP"].Crv != "Ed25519" || kty["OKP"].Alg != "EdDSA" {
+		t.Fatalf("unexpected OKP key: %v", kty["OKP"])
+	}
+}
+func Test_JWKSHandler(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(sm1.JWKSHandler(time.Hour))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/.well-known/jwks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+
+	if resp.Header.Get("Cache-Control") != "public, max-age=3600" {
+		t.Fatalf("unexpected cache header %s", resp.Header.Get("Cache-Control"))
+	}
+
+	var set JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(set.Keys) != 1 || set.Keys[0].Kty != "EC" {
+		t.Fatalf("unexpected key set %v", set)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected not modified, got %d", resp2.StatusCode)
+	}
+}
+func Test_ParseJWKSUnsupportedKeys(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["EdDSA"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := sm1.JWKS(ctx)
+
+	//keys the verifier cannot use are published alongside the usable one
+	unsupported := []JSONWebKey{
+		{Kty: "EC", Kid: "p521", Use: "sig", Crv: "P-521", X: "AQ", Y: "AQ"},
+		{Kty: "oct", Kid: "oct1", Use: "sig"},
+	}
+
+	mixed := &JSONWebKeySet{Keys: append(append([]JSONWebKey{}, unsupported...), set.Keys...)}
+
+	data, err := json.Marshal(mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vf, err := NewVerifierFromJWKS(ctx, lbcf.NewConfig(ctx), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vf.IsSessionValid(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+
+	//a document without any usable key is still refused
+	data, err = json.Marshal(&JSONWebKeySet{Keys: unsupported})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseJWKS(data); err != ErrSigningKeyNotSupported {
+		t.Fatalf("expected %v, got %v", ErrSigningKeyNotSupported, err)
+	}
+}
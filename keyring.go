@@ -0,0 +1,189 @@
+package session
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+//ringKey is a signing key held in a key ring
+type ringKey struct {
+	key       *SigningKey
+	retiredAt *time.Time
+	revoked   bool
+}
+
+//KeyRing holds the active signing key and the retiring keys which are still accepted for verification
+type KeyRing struct {
+	mux     sync.RWMutex
+	active  string
+	legacy  string
+	keys    map[string]*ringKey
+	overlap time.Duration
+	clock   Clock
+}
+
+//NewKeyRing creates a key ring which signs with the active key and keeps retired keys valid for the overlap window
+func NewKeyRing(active *SigningKey, overlap time.Duration) (*KeyRing, error) {
+	kid, err := keyID(active)
+	if err != nil {
+		return nil, err
+	}
+
+	kr := &KeyRing{
+		active:  kid,
+		legacy:  kid,
+		keys:    map[string]*ringKey{kid: {key: active}},
+		overlap: overlap,
+		clock:   systemClock{},
+	}
+
+	return kr, nil
+}
+
+//keyID returns the key id of the signing key, deriving one from the verification key and recording it on the key if it is not set
+func keyID(sk *SigningKey) (string, error) {
+	if sk == nil || sk.Method == nil {
+		return "", ErrKeyPairNotExist
+	}
+
+	if sk.KeyID != "" {
+		return sk.KeyID, nil
+	}
+
+	var material []byte
+
+	switch vk := sk.VerifyKey.(type) {
+	case []byte:
+		material = vk
+	default:
+		der, err := x509.MarshalPKIXPublicKey(vk)
+		if err != nil {
+			return "", ErrSigningKeyNotSupported
+		}
+		material = der
+	}
+
+	sum := sha256.Sum256(material)
+	sk.KeyID = base64.RawURLEncoding.EncodeToString(sum[:12])
+
+	return sk.KeyID, nil
+}
+
+//Rotate makes next the active signing key and retires the current one
+func (kr *KeyRing) Rotate(next *SigningKey) error {
+	kid, err := keyID(next)
+	if err != nil {
+		return err
+	}
+
+	kr.mux.Lock()
+	defer kr.mux.Unlock()
+
+	if _, ok := kr.keys[kid]; ok {
+		return ErrKeyIDExists
+	}
+
+	now := kr.clock.Now()
+	kr.keys[kr.active].retiredAt = &now
+	kr.keys[kid] = &ringKey{key: next}
+	kr.active = kid
+
+	return nil
+}
+
+//Revoke stops the key ring from accepting any token signed with the key id
+func (kr *KeyRing) Revoke(kid string) error {
+	kr.mux.Lock()
+	defer kr.mux.Unlock()
+
+	rk, ok := kr.keys[kid]
+	if !ok {
+		return ErrKeyIDNotExist
+	}
+
+	//the active key has to be rotated out before it can be revoked
+	if kid == kr.active {
+		return ErrKeyIDActive
+	}
+
+	rk.revoked = true
+
+	return nil
+}
+
+//setClock sets the clock used to retire keys
+func (kr *KeyRing) setClock(c Clock) {
+	kr.mux.Lock()
+	defer kr.mux.Unlock()
+
+	kr.clock = c
+}
+
+//ActiveKeyID returns the key id of the active signing key
+func (kr *KeyRing) ActiveKeyID() string {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+
+	return kr.active
+}
+
+//signingKey returns the active signing key
+func (kr *KeyRing) signingKey() *SigningKey {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+
+	return kr.keys[kr.active].key
+}
+
+//Algs returns the algorithms of the keys which are accepted for verification
+func (kr *KeyRing) Algs() []string {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+
+	var algs []string
+	seen := make(map[string]bool)
+
+	for _, rk := range kr.keys {
+		if rk.revoked || seen[rk.key.Alg()] {
+			continue
+		}
+		seen[rk.key.Alg()] = true
+		algs = append(algs, rk.key.Alg())
+	}
+
+	return algs
+}
+
+//VerificationKey returns the key used to verify a token with the key id and algorithm
+func (kr *KeyRing) VerificationKey(kid, alg string) (interface{}, error) {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+
+	//tokens issued before key ids were introduced are verified with the original key of the ring
+	//so they are retired with it rather than failing on the first rotation
+	if kid == "" {
+		kid = kr.legacy
+	}
+
+	rk, ok := kr.keys[kid]
+	if !ok {
+		return nil, ErrKeyIDNotExist
+	}
+
+	if rk.revoked {
+		return nil, ErrJwtKeyRevoked
+	}
+
+	if rk.retiredAt != nil && kr.clock.Now().After(rk.retiredAt.Add(kr.overlap)) {
+		return nil, ErrJwtKeyRetired
+	}
+
+	if rk.key.Alg() != alg {
+		return nil, ErrJwtAlgNotAllowed
+	}
+
+	return rk.key.VerifyKey, nil
+}
@@ -0,0 +1,138 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/net/context"
+)
+
+func Test_RotateKey(t *testing.T) {
+	ctx := context.Background()
+
+	keys := createTestKeys(t)
+
+	ring, err := NewKeyRing(keys["ES256"], time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sm1, err := NewMgrWithKeyRing(ctx, lbcf.NewConfig(ctx), ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldsess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldkid := ring.ActiveKeyID()
+
+	if err := sm1.RotateKey(ctx, keys["EdDSA"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if ring.ActiveKeyID() == oldkid {
+		t.Fatal("active key id should change after rotation")
+	}
+
+	newsess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sess := range []string{oldsess, newsess} {
+		if _, err := sm1.IsSessionValid(ctx, sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sm1.RevokeKey(ctx, ring.ActiveKeyID()); err != ErrKeyIDActive {
+		t.Fatal("active key should not be revoked")
+	}
+
+	if err := sm1.RevokeKey(ctx, oldkid); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, oldsess); err == nil {
+		t.Fatal("session signed with a revoked key should be rejected")
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, newsess); err != nil {
+		t.Fatal(err)
+	}
+}
+func Test_RetiredKeyOverlap(t *testing.T) {
+	ctx := context.Background()
+
+	keys := createTestKeys(t)
+
+	//no overlap window, so the retired key is rejected straight away
+	ring, err := NewKeyRing(keys["ES256"], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sm1, err := NewMgrWithKeyRing(ctx, lbcf.NewConfig(ctx), ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sm1.RotateKey(ctx, keys["ES384"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, sess); err != ErrJwtKeyRetired {
+		t.Fatalf("session signed with a retired key should be rejected after the overlap window with %v, got %v", ErrJwtKeyRetired, err)
+	}
+}
+func Test_LegacyKeyID(t *testing.T) {
+	ctx := context.Background()
+
+	keys := createTestKeys(t)
+
+	ring, err := NewKeyRing(keys["ES256"], time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clk := newTestClock()
+
+	sm1, err := NewMgrWithKeyRing(ctx, lbcf.NewConfig(ctx), ring, WithClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a token issued before key ids were introduced has no kid header
+	signer := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"iss": sm1.issuer, "jti": "s1", "exp": clk.Now().Add(2 * time.Hour).Unix()})
+
+	legacy, err := signer.SignedString(keys["ES256"].SignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sm1.RotateKey(ctx, keys["EdDSA"]); err != nil {
+		t.Fatal(err)
+	}
+
+	//the original key still verifies it within the overlap window
+	if _, err := sm1.IsSessionValid(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Advance(time.Hour + time.Second)
+
+	if _, err := sm1.IsSessionValid(ctx, legacy); err != ErrJwtKeyRetired {
+		t.Fatalf("session without a kid should be rejected once the original key is retired with %v, got %v", ErrJwtKeyRetired, err)
+	}
+}
@@ -0,0 +1,321 @@
+package session
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	lblog "github.com/lidstromberg/log"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//refreshTimeout limits how long the middleware waits for a token refresh
+const refreshTimeout = 10 * time.Second
+
+//sessionContextKey is the context key of the verified session
+type sessionContextKey struct{}
+
+//Session is a verified session carried in a request context
+type Session struct {
+	Token  string
+	Claims *SessionClaims
+}
+
+//ID returns the session id (jti)
+func (sess *Session) ID() string {
+	return sess.Claims.ID
+}
+
+//AccountID returns the user account id
+func (sess *Session) AccountID() string {
+	return sess.Claims.AccountID
+}
+
+//Email returns the user email
+func (sess *Session) Email() string {
+	return sess.Claims.Email
+}
+
+//Claim returns a claim element of the session
+func (sess *Session) Claim(element string) (interface{}, bool) {
+	return sess.Claims.Claim(element)
+}
+
+//NewSessionContext returns a copy of ctx which carries the session
+func NewSessionContext(ctx context.Context, sess *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, sess)
+}
+
+//SessionFromContext returns the session carried by ctx
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	sess, ok := ctx.Value(sessionContextKey{}).(*Session)
+	return sess, ok
+}
+
+//AuthConfig configures the authentication middleware
+type AuthConfig struct {
+	//CookieName is the cookie read when the request has no Authorization header, leave empty to only accept the header
+	CookieName string
+	//Cookies reads the session from its cookie transport when the request has no Authorization header, in place of CookieName
+	Cookies *CookieTransport
+	//RequiredRoles must all be held by the session, checked with HoldsAllRoles
+	RequiredRoles []string
+	//ErrorHandler writes the 401/403 response, http.Error is used if it is not set
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
+	//Refresh re-issues tokens which are close to expiry, it requires a verifier which can refresh sessions
+	Refresh *RefreshConfig
+}
+
+//RefreshConfig configures transparent sliding refresh in the authentication middleware
+type RefreshConfig struct {
+	//Threshold re-issues the token once its remaining lifetime is below it
+	Threshold time.Duration
+	//HeaderName is the response header which carries the new token, leave empty to not send it as a header
+	HeaderName string
+	//Cookie is the template of the Set-Cookie which carries the new token, leave nil to not send a cookie
+	//if AuthConfig.Cookies is set the new token is written through the cookie transport instead, so large tokens are chunked
+	//and only for requests which authenticated with the session cookie, bearer clients only get the header
+	Cookie *http.Cookie
+}
+
+//SessRefresher defines the refresh operation used by the middleware
+type SessRefresher interface {
+	RefreshSession(ctx context.Context, sessionID string) <-chan interface{}
+}
+
+//NewAuthMiddleware returns middleware which validates the request session once and stores it in the request context
+func NewAuthMiddleware(sv SessVerifier, cfg AuthConfig) func(http.Handler) http.Handler {
+	var rf *refresher
+
+	if cfg.Refresh != nil {
+		if sr, ok := sv.(SessRefresher); ok {
+			rf = &refresher{sr: sr, clock: verifierClock(sv), calls: make(map[string]*refreshCall)}
+		} else {
+			lblog.LogEvent("AuthMiddleware", "NewAuthMiddleware", "error", "verifier cannot refresh sessions, refresh is disabled")
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			token := cfg.requestToken(r)
+			if token == "" {
+				cfg.writeError(w, r, http.StatusUnauthorized, ErrJwtNotPresent)
+				return
+			}
+
+			//reading the claims validates the token
+			clms, err := sv.GetJwtClaim(ctx, token)
+			if err != nil {
+				cfg.writeError(w, r, http.StatusUnauthorized, err)
+				return
+			}
+
+			//the roles are checked on the claims which have just been verified
+			if len(cfg.RequiredRoles) > 0 && !sv.HoldsAllRoles(clms, cfg.RequiredRoles...) {
+				cfg.writeError(w, r, http.StatusForbidden, ErrRoleNotHeld)
+				return
+			}
+
+			sess := &Session{Token: token, Claims: clms}
+
+			if rf != nil {
+				rf.slide(w, r, cfg, sess)
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewSessionContext(ctx, sess)))
+		})
+	}
+}
+
+//AuthMiddleware returns middleware which validates the request session against the manager
+func (sessMgr *SessMgr) AuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
+	return NewAuthMiddleware(sessMgr, cfg)
+}
+
+//requestToken reads the bearer token from the Authorization header, or else from the session cookie
+func (cfg AuthConfig) requestToken(r *http.Request) string {
+	if hdr := r.Header.Get("Authorization"); hdr != "" {
+		token, _ := bearerToken(hdr)
+		return token
+	}
+
+	if cfg.Cookies != nil {
+		token, _ := cfg.Cookies.ReadSession(r)
+		return token
+	}
+
+	if cfg.CookieName == "" {
+		return ""
+	}
+
+	ck, err := r.Cookie(cfg.CookieName)
+	if err != nil {
+		return ""
+	}
+
+	return ck.Value
+}
+
+//bearerToken reads the token of a bearer Authorization header, other schemes such as Basic are not bearer tokens
+func bearerToken(hdr string) (string, bool) {
+	scheme, token, ok := strings.Cut(hdr, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
+
+//writeError writes an authentication or authorisation failure
+func (cfg AuthConfig) writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if EnvDebugOn {
+		lblog.LogEvent("AuthMiddleware", "writeError", "info", err.Error())
+	}
+
+	if cfg.ErrorHandler != nil {
+		cfg.ErrorHandler(w, r, status, err)
+		return
+	}
+
+	if status == http.StatusUnauthorized {
+		//a request without any token gets a bare challenge (RFC 6750)
+		if err == ErrJwtNotPresent {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		}
+	}
+
+	http.Error(w, http.StatusText(status), status)
+}
+
+//refreshCall is a refresh of one token, shared by every request carrying that token
+type refreshCall struct {
+	done  chan struct{}
+	token string
+	exp   time.Time
+	until time.Time
+}
+
+//refresher re-issues tokens for the middleware, one refresh per token
+type refresher struct {
+	sr    SessRefresher
+	clock Clock
+	mux   sync.Mutex
+	calls map[string]*refreshCall
+}
+
+//slide re-issues the session token if it is close to expiry and writes the new token to the response
+func (rf *refresher) slide(w http.ResponseWriter, r *http.Request, cfg AuthConfig, sess *Session) {
+	if sess.Claims.ExpiresAt == nil {
+		return
+	}
+
+	until := sess.Claims.ExpiresAt.Time
+	if until.Sub(rf.clock.Now()) >= cfg.Refresh.Threshold {
+		return
+	}
+
+	call := rf.refresh(sess.Token, until)
+	if call.token == "" || call.token == sess.Token {
+		return
+	}
+
+	if cfg.Refresh.HeaderName != "" {
+		w.Header().Set(cfg.Refresh.HeaderName, call.token)
+	}
+
+	//the cookie transport chunks the token and expires the chunks of the old one
+	//a request which carried an Authorization header was not authenticated by its cookies
+	if cfg.Cookies != nil {
+		if r.Header.Get("Authorization") == "" && cfg.Cookies.hasSessionCookie(r) {
+			if err := cfg.Cookies.WriteSession(r.Context(), w, r, call.token); err != nil {
+				lblog.LogEvent("AuthMiddleware", "slide", "error", err.Error())
+			}
+		}
+		return
+	}
+
+	if cfg.Refresh.Cookie != nil {
+		ck := *cfg.Refresh.Cookie
+		ck.Value = call.token
+		ck.Expires = call.exp
+		ck.MaxAge = int(call.exp.Sub(rf.clock.Now()).Seconds())
+		http.SetCookie(w, &ck)
+	}
+}
+
+//refresh returns the refreshed token, concurrent and later requests with the same token reuse the first refresh which re-issued it
+func (rf *refresher) refresh(token string, until time.Time) *refreshCall {
+	rf.mux.Lock()
+
+	//forget refreshes of tokens which have expired anyway
+	now := rf.clock.Now()
+	for k, c := range rf.calls {
+		if now.After(c.until) {
+			delete(rf.calls, k)
+		}
+	}
+
+	if call, ok := rf.calls[token]; ok {
+		rf.mux.Unlock()
+		<-call.done
+		return call
+	}
+
+	call := &refreshCall{done: make(chan struct{}), until: until}
+	rf.calls[token] = call
+	rf.mux.Unlock()
+
+	defer close(call.done)
+
+	//the refresh is not tied to the request which happened to trigger it
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		lblog.LogEvent("AuthMiddleware", "refresh", "error", ctx.Err().Error())
+	case r, ok := <-rf.sr.RefreshSession(ctx, token):
+		if !ok {
+			break
+		}
+		if err, ok := r.(error); ok {
+			lblog.LogEvent("AuthMiddleware", "refresh", "error", err.Error())
+			break
+		}
+		if newtoken, ok := r.(string); ok {
+			call.token = newtoken
+			call.exp = tokenExpiry(newtoken)
+		}
+	}
+
+	//a failed refresh is retried by the next request, as is a token which the manager handed back unchanged
+	//because it was not yet within the refresh-ahead threshold of the session policy
+	if call.token == "" || call.token == token {
+		rf.mux.Lock()
+		delete(rf.calls, token)
+		rf.mux.Unlock()
+	}
+
+	return call
+}
+
+//tokenExpiry reads the expiry of a token which the manager has just signed
+func tokenExpiry(token string) time.Time {
+	clms := &SessionClaims{}
+
+	if _, _, err := jwt.NewParser().ParseUnverified(token, clms); err != nil || clms.ExpiresAt == nil {
+		return time.Time{}
+	}
+
+	return clms.ExpiresAt.Time
+}
@@ -0,0 +1,226 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+)
+
+func Test_AuthMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shdr := createBaseClaims()
+	shdr.ID = "dummyUser1NoRoles"
+	shdr.Roles = nil
+
+	bare, err := sm1.NewSession(ctx, shdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found *Session
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		found, _ = SessionFromContext(r.Context())
+	})
+
+	cases := []struct {
+		name   string
+		cfg    AuthConfig
+		header string
+		cookie string
+		status int
+	}{
+		{"bearer", AuthConfig{}, "Bearer " + sess, "", http.StatusOK},
+		{"cookie", AuthConfig{CookieName: "sess"}, "", sess, http.StatusOK},
+		{"cookie not enabled", AuthConfig{}, "", sess, http.StatusUnauthorized},
+		{"missing", AuthConfig{}, "", "", http.StatusUnauthorized},
+		{"invalid", AuthConfig{}, "Bearer " + sess + "x", "", http.StatusUnauthorized},
+		{"role held", AuthConfig{RequiredRoles: []string{"testapp1", "testapp2"}}, "Bearer " + sess, "", http.StatusOK},
+		{"role not held", AuthConfig{RequiredRoles: []string{"testapp3"}}, "Bearer " + sess, "", http.StatusForbidden},
+		{"no roles", AuthConfig{RequiredRoles: []string{"testapp1"}}, "Bearer " + bare, "", http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		found = nil
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		if tc.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "sess", Value: tc.cookie})
+		}
+
+		rec := httptest.NewRecorder()
+		sm1.AuthMiddleware(tc.cfg)(next).ServeHTTP(rec, req)
+
+		if rec.Code != tc.status {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.status, rec.Code)
+		}
+
+		if tc.status == http.StatusOK && (found == nil || found.AccountID() != "dummyUser1" || found.Token != sess) {
+			t.Fatalf("%s: session was not stored in the request context", tc.name)
+		}
+	}
+}
+func Test_AuthMiddlewareErrorHandler(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := AuthConfig{
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, status int, err error) {
+			w.WriteHeader(http.StatusTeapot)
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	sm1.AuthMiddleware(cfg)(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("expected the custom error handler to run, got %d", rec.Code)
+	}
+}
+func Test_AuthMiddlewareRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	sp := SessionPolicy{InitialLifetime: 10 * time.Minute, IdleTimeout: 30 * time.Minute}
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithSessionPolicy(sp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := AuthConfig{
+		Refresh: &RefreshConfig{
+			Threshold:  15 * time.Minute,
+			HeaderName: "X-Session-Token",
+			Cookie:     &http.Cookie{Name: "sess", Path: "/", HttpOnly: true},
+		},
+	}
+
+	handler := sm1.AuthMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	//concurrent requests with the same token share one refreshed token
+	var wg sync.WaitGroup
+	results := make(chan *httptest.ResponseRecorder, 8)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+sess)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			results <- rec
+		}()
+	}
+
+	wg.Wait()
+	close(results)
+
+	tokens := make(map[string]bool)
+	for rec := range results {
+		newsess := rec.Header().Get("X-Session-Token")
+		if newsess == "" || newsess == sess {
+			t.Fatal("token should be refreshed inside the threshold")
+		}
+
+		cks := rec.Result().Cookies()
+		if len(cks) != 1 || cks[0].Value != newsess || cks[0].MaxAge <= 0 {
+			t.Fatal("refreshed token should be set as a cookie")
+		}
+
+		tokens[newsess] = true
+	}
+
+	if len(tokens) != 1 {
+		t.Fatalf("expected a single refreshed token, got %d", len(tokens))
+	}
+
+	//outside the threshold the token is left alone
+	cfg.Refresh.Threshold = 5 * time.Minute
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sess)
+
+	rec := httptest.NewRecorder()
+	sm1.AuthMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+
+	if rec.Header().Get("X-Session-Token") != "" {
+		t.Fatal("token should not be refreshed outside the threshold")
+	}
+}
+func Test_AuthMiddlewareRefreshAhead(t *testing.T) {
+	ctx := context.Background()
+
+	clk := newTestClock()
+
+	//the manager only re-issues within 2 minutes of expiry, the middleware asks within 20 minutes
+	sp := SessionPolicy{InitialLifetime: 10 * time.Minute, IdleTimeout: 10 * time.Minute, RefreshAhead: 2 * time.Minute}
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithSessionPolicy(sp), WithClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := sm1.AuthMiddleware(AuthConfig{Refresh: &RefreshConfig{Threshold: 20 * time.Minute, HeaderName: "X-Session-Token"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+sess)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		return rec.Header().Get("X-Session-Token")
+	}
+
+	//outside the refresh-ahead window the manager hands the token back unchanged
+	if newsess := serve(); newsess != "" {
+		t.Fatal("token should not be re-issued outside the refresh-ahead window")
+	}
+
+	//the unchanged token is not remembered, so the same token is refreshed once it is due
+	clk.Advance(9 * time.Minute)
+
+	if newsess := serve(); newsess == "" || newsess == sess {
+		t.Fatal("token should be refreshed within the refresh-ahead window")
+	}
+}
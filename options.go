@@ -0,0 +1,144 @@
+package session
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//settings holds the construction options of session managers and verifiers
+type settings struct {
+	algs        []string
+	revocations RevocationStore
+	sessions    SessionStore
+	families    FamilyStore
+	policy      *SessionPolicy
+	roles       map[string][]string
+	evaluator   PolicyEvaluator
+	audience    []string
+	trusted     map[string]KeySet
+	clock       Clock
+	leeway      *time.Duration
+}
+
+//Option configures a session manager or verifier at construction time
+type Option func(*settings)
+
+//newSettings applies the options to an empty settings
+func newSettings(opts []Option) *settings {
+	st := &settings{}
+
+	for _, opt := range opts {
+		opt(st)
+	}
+
+	return st
+}
+
+//WithAllowedAlgs sets the jwt algorithms which are accepted when reading tokens
+//by default the algorithms of the keys in the key set are accepted
+func WithAllowedAlgs(algs ...string) Option {
+	return func(st *settings) {
+		st.algs = algs
+	}
+}
+
+//WithRevocationStore sets the store used to revoke sessions before they expire
+//managers default to an in-memory store, verifiers only check revocations if a store is set
+func WithRevocationStore(rs RevocationStore) Option {
+	return func(st *settings) {
+		st.revocations = rs
+	}
+}
+
+//WithSessionStore sets the store in which a manager records a login candidate for every new session
+func WithSessionStore(ss SessionStore) Option {
+	return func(st *settings) {
+		st.sessions = ss
+	}
+}
+
+//WithFamilyStore sets the store which tracks refresh token rotation
+//managers default to an in-memory store, verifiers only check family revocations if a store is set
+func WithFamilyStore(fs FamilyStore) Option {
+	return func(st *settings) {
+		st.families = fs
+	}
+}
+
+//WithSessionPolicy sets the session lifetime policy of a manager, replacing the config values
+func WithSessionPolicy(sp SessionPolicy) Option {
+	return func(st *settings) {
+		st.policy = &sp
+	}
+}
+
+//WithRoleHierarchy sets the roles implied by each role, replacing the config value
+func WithRoleHierarchy(roles map[string][]string) Option {
+	return func(st *settings) {
+		st.roles = roles
+	}
+}
+
+//WithPolicyEvaluator sets the policy evaluator which decides Authorize requests
+func WithPolicyEvaluator(pe PolicyEvaluator) Option {
+	return func(st *settings) {
+		st.evaluator = pe
+	}
+}
+
+//WithAudience sets the audiences which tokens are issued for and which are accepted, replacing the config value
+//a token is accepted if it is issued for one of the audiences, with no audiences set the aud claim is not checked
+func WithAudience(aud ...string) Option {
+	return func(st *settings) {
+		st.audience = append([]string{}, aud...)
+	}
+}
+
+//WithTrustedIssuer accepts tokens of another issuer, verified against its own key set
+//tokens must otherwise carry the configured issuer, the option can be repeated for each trusted issuer
+func WithTrustedIssuer(iss string, keys KeySet) Option {
+	return func(st *settings) {
+		if st.trusted == nil {
+			st.trusted = make(map[string]KeySet)
+		}
+
+		st.trusted[iss] = keys
+	}
+}
+
+//WithClock sets the clock used to issue and validate tokens, by default the wall clock
+//the key ring and the built-in revocation, family and session stores follow the same clock
+func WithClock(c Clock) Option {
+	return func(st *settings) {
+		st.clock = c
+	}
+}
+
+//WithLeeway sets the clock skew allowed when checking exp, nbf and iat, replacing the config value
+func WithLeeway(d time.Duration) Option {
+	return func(st *settings) {
+		st.leeway = &d
+	}
+}
+
+//checkAllowedAlgs checks that every allowed algorithm is known and, if set, that the signing algorithm is allowed
+func checkAllowedAlgs(algs []string, signAlg string) error {
+	signAllowed := signAlg == ""
+
+	for _, alg := range algs {
+		if jwt.GetSigningMethod(alg) == nil || alg == jwt.SigningMethodNone.Alg() {
+			return ErrJwtAlgNotAllowed
+		}
+
+		if alg == signAlg {
+			signAllowed = true
+		}
+	}
+
+	if !signAllowed {
+		return ErrJwtAlgNotAllowed
+	}
+
+	return nil
+}
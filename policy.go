@@ -0,0 +1,77 @@
+package session
+
+import (
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	lbcf "github.com/lidstromberg/config"
+)
+
+//SessionPolicy controls the lifetime of the sessions issued by a manager
+type SessionPolicy struct {
+	//InitialLifetime is the lifetime of a new session
+	InitialLifetime time.Duration
+	//IdleTimeout is the lifetime granted by each refresh, so a session ends if it is idle for longer
+	IdleTimeout time.Duration
+	//AbsoluteTimeout is the lifetime measured from the original authentication, zero means unlimited
+	AbsoluteTimeout time.Duration
+	//RefreshAhead only re-issues a token on refresh when its remaining lifetime is below it, zero always re-issues
+	RefreshAhead time.Duration
+}
+
+//newConfigPolicy creates the session policy from the session config
+//both the initial lifetime and the idle timeout default to EnvSessExtensionMin
+func newConfigPolicy(ctx context.Context, bc lbcf.ConfigSetting) (*SessionPolicy, error) {
+	ev, err := strconv.Atoi(bc.GetConfigValue(ctx, "EnvSessExtensionMin"))
+	if err != nil {
+		return nil, err
+	}
+
+	mv, err := optionalConfigInt(ctx, bc, "EnvSessMaxLifeMin", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rv, err := optionalConfigInt(ctx, bc, "EnvSessRefreshAheadMin", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &SessionPolicy{
+		InitialLifetime: time.Minute * time.Duration(ev),
+		IdleTimeout:     time.Minute * time.Duration(ev),
+		AbsoluteTimeout: time.Minute * time.Duration(mv),
+		RefreshAhead:    time.Minute * time.Duration(rv),
+	}
+
+	return sp, nil
+}
+
+//validate checks that the policy lifetimes are usable
+func (sp *SessionPolicy) validate() error {
+	if sp.InitialLifetime <= 0 || sp.IdleTimeout <= 0 || sp.AbsoluteTimeout < 0 || sp.RefreshAhead < 0 {
+		return ErrSessionPolicyInvalid
+	}
+
+	if sp.AbsoluteTimeout > 0 && sp.AbsoluteTimeout < sp.InitialLifetime {
+		return ErrSessionPolicyInvalid
+	}
+
+	return nil
+}
+
+//expiry returns now plus the lifetime, limited to the absolute timeout
+func (sp *SessionPolicy) expiry(now time.Time, lifetime time.Duration) time.Time {
+	if sp.AbsoluteTimeout > 0 && lifetime > sp.AbsoluteTimeout {
+		lifetime = sp.AbsoluteTimeout
+	}
+
+	return now.Add(lifetime)
+}
+
+//dueRefresh returns true if a token expiring at exp should be re-issued
+func (sp *SessionPolicy) dueRefresh(now, exp time.Time) bool {
+	return sp.RefreshAhead <= 0 || exp.Sub(now) < sp.RefreshAhead
+}
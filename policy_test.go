@@ -0,0 +1,98 @@
+package session
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+)
+
+func addWait() *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	return &wg
+}
+func createPolicySess(t *testing.T, ctx context.Context, sp SessionPolicy) (*SessMgr, string, time.Time) {
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithSessionPolicy(sp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clms, err := sm1.GetJwtClaim(ctx, sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sm1, sess, clms.ExpiresAt.Time
+}
+func Test_SessionPolicyRefreshAhead(t *testing.T) {
+	ctx := context.Background()
+
+	sp := SessionPolicy{InitialLifetime: 10 * time.Minute, IdleTimeout: 30 * time.Minute, RefreshAhead: 5 * time.Minute}
+
+	sm1, sess, exp := createPolicySess(t, ctx, sp)
+
+	if d := time.Until(exp); d > 10*time.Minute || d < 9*time.Minute {
+		t.Fatalf("expected the initial lifetime, got %v", d)
+	}
+
+	//the token has more than the refresh-ahead threshold left, so it is not re-issued
+	newsess := PollFn(ctx, addWait(), sess, sm1.RefreshSession(ctx, sess))
+	if newsess != sess {
+		t.Fatal("token should not be re-issued outside the refresh-ahead threshold")
+	}
+
+	sp.RefreshAhead = 15 * time.Minute
+
+	sm2, sess2, _ := createPolicySess(t, ctx, sp)
+
+	newsess = PollFn(ctx, addWait(), sess2, sm2.RefreshSession(ctx, sess2))
+	if newsess == sess2 {
+		t.Fatal("token should be re-issued inside the refresh-ahead threshold")
+	}
+
+	clms2, err := sm2.GetJwtClaim(ctx, newsess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Until(clms2.ExpiresAt.Time); d > 30*time.Minute || d < 29*time.Minute {
+		t.Fatalf("expected the idle timeout, got %v", d)
+	}
+}
+func Test_SessionPolicyAbsoluteTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	sp := SessionPolicy{InitialLifetime: 10 * time.Minute, IdleTimeout: 30 * time.Minute, AbsoluteTimeout: 20 * time.Minute}
+
+	sm1, sess, _ := createPolicySess(t, ctx, sp)
+
+	newsess := PollFn(ctx, addWait(), sess, sm1.RefreshSession(ctx, sess))
+
+	clms, err := sm1.GetJwtClaim(ctx, newsess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Until(clms.ExpiresAt.Time); d > 20*time.Minute || d < 19*time.Minute {
+		t.Fatalf("expected the absolute timeout to cap the refresh, got %v", d)
+	}
+}
+func Test_SessionPolicyInvalid(t *testing.T) {
+	ctx := context.Background()
+
+	sp := SessionPolicy{InitialLifetime: 30 * time.Minute, IdleTimeout: 10 * time.Minute, AbsoluteTimeout: 20 * time.Minute}
+
+	if _, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithSessionPolicy(sp)); err != ErrSessionPolicyInvalid {
+		t.Fatalf("expected invalid policy error, got %v", err)
+	}
+}
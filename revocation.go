@@ -0,0 +1,71 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+//RevocationStore records revoked session ids (jti) until the revoked token would have expired
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, exp time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+//MemRevocationStore is an in-memory RevocationStore
+type MemRevocationStore struct {
+	mux     sync.Mutex
+	revoked map[string]time.Time
+	clock   Clock
+}
+
+//NewMemRevocationStore creates an empty in-memory revocation store
+func NewMemRevocationStore() *MemRevocationStore {
+	return &MemRevocationStore{revoked: make(map[string]time.Time), clock: systemClock{}}
+}
+
+//setClock sets the clock used to expire revocations
+func (rs *MemRevocationStore) setClock(c Clock) {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+
+	rs.clock = c
+}
+
+//Revoke records the jti as revoked until exp
+func (rs *MemRevocationStore) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+
+	now := rs.clock.Now()
+
+	//drop entries for tokens which have expired anyway
+	for id, until := range rs.revoked {
+		if now.After(until) {
+			delete(rs.revoked, id)
+		}
+	}
+
+	rs.revoked[jti] = exp
+
+	return nil
+}
+
+//IsRevoked returns true if the jti has been revoked and the revoked token has not yet expired
+func (rs *MemRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+
+	until, ok := rs.revoked[jti]
+	if !ok {
+		return false, nil
+	}
+
+	if rs.clock.Now().After(until) {
+		delete(rs.revoked, jti)
+		return false, nil
+	}
+
+	return true, nil
+}
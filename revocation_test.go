@@ -0,0 +1,49 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func Test_MemRevocationStore(t *testing.T) {
+	ctx := context.Background()
+
+	rs := NewMemRevocationStore()
+
+	if err := rs.Revoke(ctx, "live", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rs.Revoke(ctx, "expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := rs.IsRevoked(ctx, "live")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !revoked {
+		t.Fatal("live jti should be revoked")
+	}
+
+	revoked, err = rs.IsRevoked(ctx, "expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked {
+		t.Fatal("expired jti should be dropped from the store")
+	}
+
+	revoked, err = rs.IsRevoked(ctx, "unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked {
+		t.Fatal("unknown jti should not be revoked")
+	}
+}
@@ -0,0 +1,123 @@
+package session
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	lbcf "github.com/lidstromberg/config"
+)
+
+//roleHierarchy maps each role to every role it implies, directly or through other roles
+type roleHierarchy map[string][]string
+
+//newConfigRoleHierarchy creates the role hierarchy from the session config, no hierarchy is set if the config is empty
+func newConfigRoleHierarchy(ctx context.Context, bc lbcf.ConfigSetting) (roleHierarchy, error) {
+	val := bc.GetConfigValue(ctx, "EnvSessRoleHierarchy")
+	if val == "" {
+		return nil, nil
+	}
+
+	defs := make(map[string][]string)
+	if err := json.Unmarshal([]byte(val), &defs); err != nil {
+		return nil, ErrRoleHierarchyInvalid
+	}
+
+	return newRoleHierarchy(defs)
+}
+
+//newRoleHierarchy expands the role definitions, a role which implies itself is an error
+func newRoleHierarchy(defs map[string][]string) (roleHierarchy, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+
+	rh := make(roleHierarchy, len(defs))
+	state := make(map[string]int, len(defs))
+
+	var visit func(role string) error
+	visit = func(role string) error {
+		switch state[role] {
+		case visiting:
+			return ErrRoleHierarchyInvalid
+		case done:
+			return nil
+		}
+
+		state[role] = visiting
+
+		seen := make(map[string]bool)
+		var implied []string
+
+		for _, child := range defs[role] {
+			if child == "" {
+				return ErrRoleHierarchyInvalid
+			}
+
+			if err := visit(child); err != nil {
+				return err
+			}
+
+			for _, r := range append([]string{child}, rh[child]...) {
+				if !seen[r] {
+					seen[r] = true
+					implied = append(implied, r)
+				}
+			}
+		}
+
+		rh[role] = implied
+		state[role] = done
+
+		return nil
+	}
+
+	for role := range defs {
+		if err := visit(role); err != nil {
+			return nil, err
+		}
+	}
+
+	return rh, nil
+}
+
+//grants returns the held roles and every role they imply
+func (rh roleHierarchy) grants(held []string) []string {
+	if len(rh) == 0 {
+		return held
+	}
+
+	seen := make(map[string]bool, len(held))
+	var out []string
+
+	for _, role := range held {
+		for _, r := range append([]string{role}, rh[role]...) {
+			if !seen[r] {
+				seen[r] = true
+				out = append(out, r)
+			}
+		}
+	}
+
+	return out
+}
+
+//holdsRole returns true if one of the granted roles matches the role, granted roles can be wildcard patterns such as billing:*
+func holdsRole(granted []string, roleName string) bool {
+	for _, role := range granted {
+		if role == roleName {
+			return true
+		}
+
+		if strings.ContainsAny(role, "*?[") {
+			if ok, err := path.Match(role, roleName); err == nil && ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
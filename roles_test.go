@@ -0,0 +1,105 @@
+package session
+
+import (
+	"testing"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+)
+
+func Test_RoleHierarchy(t *testing.T) {
+	rh, err := newRoleHierarchy(map[string][]string{
+		"admin":  {"editor", "billing:*"},
+		"editor": {"viewer"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	granted := rh.grants([]string{"admin"})
+
+	cases := []struct {
+		role string
+		held bool
+	}{
+		{"admin", true},
+		{"editor", true},
+		{"viewer", true},
+		{"billing:read", true},
+		{"billing:write", true},
+		{"billing", false},
+		{"reports:read", false},
+	}
+
+	for _, tc := range cases {
+		if holdsRole(granted, tc.role) != tc.held {
+			t.Fatalf("%s: expected held %v", tc.role, tc.held)
+		}
+	}
+
+	if holdsRole(rh.grants([]string{"editor"}), "admin") {
+		t.Fatal("a role should not imply the roles above it")
+	}
+
+	cycles := []map[string][]string{
+		{"admin": {"admin"}},
+		{"admin": {"editor"}, "editor": {"viewer"}, "viewer": {"admin"}},
+		{"admin": {""}},
+	}
+
+	for _, defs := range cycles {
+		if _, err := newRoleHierarchy(defs); err != ErrRoleHierarchyInvalid {
+			t.Fatalf("%v: expected %v, got %v", defs, ErrRoleHierarchyInvalid, err)
+		}
+	}
+}
+func Test_CheckUserRoleHierarchy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Setenv("JWT_ROLEHIERARCHY", `{"testapp1":["testapp1:editor"],"testapp1:editor":["testapp1:viewer"]}`)
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shdr := createBaseClaims()
+	shdr.Roles = []string{"testapp1", "billing:*"}
+
+	sess, err := sm1.NewSession(ctx, shdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, role := range []string{"testapp1", "testapp1:viewer", "billing:read"} {
+		ok, err := sm1.CheckUserRole(ctx, sess, role)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ok {
+			t.Fatalf("expected %s to be held", role)
+		}
+	}
+
+	ok, err := sm1.HasAllRoles(ctx, sess, "testapp1:editor", "billing:write", "reports:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Fatal("reports:read should not be held")
+	}
+
+	//the option replaces the config hierarchy, and cycles are refused at construction
+	if _, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithRoleHierarchy(map[string][]string{"a": {"b"}, "b": {"a"}})); err != ErrRoleHierarchyInvalid {
+		t.Fatalf("expected %v, got %v", ErrRoleHierarchyInvalid, err)
+	}
+
+	t.Setenv("JWT_ROLEHIERARCHY", `{"testapp1":"testapp2"}`)
+
+	if _, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"]); err != ErrRoleHierarchyInvalid {
+		t.Fatalf("expected %v, got %v", ErrRoleHierarchyInvalid, err)
+	}
+}
@@ -1,9 +1,8 @@
 package session
 
 import (
-	"fmt"
+	"encoding/json"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -18,22 +17,27 @@ import (
 
 //SessMgr handles jwts
 type SessMgr struct {
-	kp        *kp.KeyPair
-	bc        lbcf.ConfigSetting
-	extendVal int
-	issuer    string
+	*Verifier
+	ring       *KeyRing
+	sessions   SessionStore
+	policy     SessionPolicy
+	accessVal  int
+	refreshVal int
+	issuer     string
 }
 
 //SessProvider defines the public operations of a session manager
 type SessProvider interface {
-	NewSession(ctx context.Context, shdr map[string]interface{}) (string, error)
-	CheckUserRole(ctx context.Context, sessionID string, roleName string) (bool, error)
-	GetJwtClaim(ctx context.Context, sessionID string) (map[string]interface{}, error)
-	GetJwtClaimElement(ctx context.Context, sessionID, element string) (interface{}, error)
-	IsSessionValid(ctx context.Context, sessionID string) (bool, error)
+	SessVerifier
+	NewSession(ctx context.Context, shdr *SessionClaims) (string, error)
 	RefreshSession(ctx context.Context, sessionID string) <-chan interface{}
 	SetAppClaim(ctx context.Context, sessionID string, appName string, appClaim string) (string, error)
 	DeleteAppClaim(ctx context.Context, sessionID string, appName string) (string, error)
+	ModifyClaims(ctx context.Context, sessionID string, fn func(*ClaimsEditor) error) (string, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	NewSessionPair(ctx context.Context, shdr *SessionClaims) (*TokenPair, error)
+	RefreshSessionPair(ctx context.Context, refreshToken string) (*TokenPair, error)
+	NewAudienceToken(ctx context.Context, sessionID string, audience string, lifetime time.Duration) (string, error)
 }
 
 //DrainFn drains a channel until it is closed
@@ -76,239 +80,320 @@ func PollFn(ctx context.Context, wg *sync.WaitGroup, sessid string, c <-chan int
 	return newsess
 }
 
-//NewMgr creates a new credential manager
-func NewMgr(ctx context.Context, bc lbcf.ConfigSetting, kpr *kp.KeyPair) (*SessMgr, error) {
-	preflight(ctx, bc)
-
-	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "NewMgr", "info", "start")
+//NewMgr creates a new credential manager which signs RS256 tokens with the keypair
+func NewMgr(ctx context.Context, bc lbcf.ConfigSetting, kpr *kp.KeyPair, opts ...Option) (*SessMgr, error) {
+	sk, err := NewRSAKey(kpr)
+	if err != nil {
+		return nil, err
 	}
 
+	return NewMgrWithKey(ctx, bc, sk, opts...)
+}
+
+//NewMgrWithKey creates a new credential manager which signs tokens with the signing key
+func NewMgrWithKey(ctx context.Context, bc lbcf.ConfigSetting, sk *SigningKey, opts ...Option) (*SessMgr, error) {
+	preflight(ctx, bc)
+
 	ev, err := strconv.Atoi(bc.GetConfigValue(ctx, "EnvSessExtensionMin"))
 	if err != nil {
 		return nil, err
 	}
 
-	sm1 := &SessMgr{
-		kp:        kpr,
-		bc:        bc,
-		extendVal: ev,
-		issuer:    bc.GetConfigValue(ctx, "EnvSessTokenIssuer"),
+	//retired keys stay valid for the key overlap window, which defaults to the token lifetime
+	ov, err := optionalConfigInt(ctx, bc, "EnvSessKeyOverlapMin", ev)
+	if err != nil {
+		return nil, err
 	}
 
-	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "NewMgr", "info", "end")
+	ring, err := NewKeyRing(sk, time.Minute*time.Duration(ov))
+	if err != nil {
+		return nil, err
 	}
 
-	return sm1, nil
+	return newMgr(ctx, bc, ring, opts...)
 }
 
-//NewSession returns a signed jwt as a string
-func (sessMgr *SessMgr) NewSession(ctx context.Context, shdr map[string]interface{}) (string, error) {
+//NewMgrWithKeyRing creates a new credential manager which signs and verifies tokens with the key ring
+func NewMgrWithKeyRing(ctx context.Context, bc lbcf.ConfigSetting, ring *KeyRing, opts ...Option) (*SessMgr, error) {
+	preflight(ctx, bc)
+
+	return newMgr(ctx, bc, ring, opts...)
+}
+
+//newMgr creates the credential manager once the config has passed preflight
+func newMgr(ctx context.Context, bc lbcf.ConfigSetting, ring *KeyRing, opts ...Option) (*SessMgr, error) {
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "NewSession", "info", "start")
+		lblog.LogEvent("SessMgr", "newMgr", "info", "start")
 	}
 
-	tokenstring, err := sessMgr.issueJwt(ctx, shdr)
-	if err != nil {
-		return "", err
+	if ring == nil {
+		return nil, ErrKeyPairNotExist
 	}
 
-	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "NewSession", "info", "end")
+	ev, err := strconv.Atoi(bc.GetConfigValue(ctx, "EnvSessExtensionMin"))
+	if err != nil {
+		return nil, err
 	}
 
-	return tokenstring, nil
-}
+	//access tokens default to the session extension, refresh tokens to one day
+	av, err := optionalConfigInt(ctx, bc, "EnvSessAccessMin", ev)
+	if err != nil {
+		return nil, err
+	}
 
-//extractJwt converts a signed jwt string to a jwt token
-func (sessMgr *SessMgr) extractJwt(sessionID string) (*jwt.Token, error) {
-	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "extractJwt", "info", "start")
+	rv, err := optionalConfigInt(ctx, bc, "EnvSessRefreshMin", 1440)
+	if err != nil {
+		return nil, err
 	}
 
-	token, err := jwt.Parse(sessionID, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	st := newSettings(opts)
+
+	//the policy comes from config unless one is set
+	if st.policy == nil {
+		st.policy, err = newConfigPolicy(ctx, bc)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		return sessMgr.kp.GetPubKey(), nil
-	})
+	if err := st.policy.validate(); err != nil {
+		return nil, err
+	}
+
+	//managers can always revoke their own sessions and rotate their own refresh tokens
+	if st.revocations == nil {
+		st.revocations = NewMemRevocationStore()
+	}
+
+	if st.families == nil {
+		st.families = NewMemFamilyStore()
+	}
+
+	//the manager verifies its own tokens against the key ring
+	verifier, err := newVerifier(ctx, bc, ring, st)
 	if err != nil {
 		return nil, err
 	}
 
-	//only return if the token is valid
-	//sufficient to check iss, iat, nbf
-	//each application should check its own appclaims
-	if !token.Valid {
-		return nil, ErrJwtInvalidSession
+	sm1 := &SessMgr{
+		Verifier:   verifier,
+		ring:       ring,
+		sessions:   st.sessions,
+		policy:     *st.policy,
+		accessVal:  av,
+		refreshVal: rv,
+		issuer:     bc.GetConfigValue(ctx, "EnvSessTokenIssuer"),
+	}
+
+	if err := checkAllowedAlgs(sm1.validMethods(), ring.signingKey().Alg()); err != nil {
+		return nil, err
 	}
 
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "extractJwt", "info", "end")
+		lblog.LogEvent("SessMgr", "newMgr", "info", "end")
 	}
 
-	return token, nil
+	return sm1, nil
 }
 
-//checkRoleToken checks that targetClaims string exists in appRoleClaims string
-func (sessMgr *SessMgr) checkRoleToken(appRoleClaims string, targetClaims string, delimiter string) bool {
+//RotateKey makes next the active signing key, the previous key remains valid for the key overlap window
+func (sessMgr *SessMgr) RotateKey(ctx context.Context, next *SigningKey) error {
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "checkRoleToken", "info", "start")
+		lblog.LogEvent("SessMgr", "RotateKey", "info", "start")
 	}
 
-	var targetLocated = false
-	for _, element := range strings.Split(appRoleClaims, delimiter) {
-		if element == targetClaims {
-			targetLocated = true
-			break
-		}
+	if next == nil || next.Method == nil {
+		return ErrKeyPairNotExist
 	}
 
-	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "checkRoleToken", "info", "end")
+	//an explicit allowlist must already permit the algorithm of the new key
+	if sessMgr.algs != nil {
+		if err := checkAllowedAlgs(sessMgr.algs, next.Alg()); err != nil {
+			return err
+		}
 	}
 
-	return targetLocated
-}
+	if err := sessMgr.ring.Rotate(next); err != nil {
+		return err
+	}
 
-//issueJwt adds the jwt claim to the session header and returns the token string
-func (sessMgr *SessMgr) issueJwt(ctx context.Context, sesshdr map[string]interface{}) (string, error) {
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "issueJwt", "info", "start")
+		lblog.LogEvent("SessMgr", "RotateKey", "info", "end")
 	}
 
-	now := time.Now()
+	return nil
+}
 
-	//create a map claims with the custom elements
-	clms := jwt.MapClaims{
-		"exp":         now.Add(time.Minute * time.Duration(sessMgr.extendVal)).Unix(),
-		ConstJwtID:    sesshdr[ConstJwtID],
-		"iss":         sessMgr.issuer,
-		"nbf":         now.Unix(),
-		"iat":         now.Unix(),
-		ConstJwtRole:  sesshdr[ConstJwtRole],
-		ConstJwtAccID: sesshdr[ConstJwtAccID],
-		ConstJwtEml:   sesshdr[ConstJwtEml],
+//RevokeKey invalidates every token signed with the key id
+func (sessMgr *SessMgr) RevokeKey(ctx context.Context, kid string) error {
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "RevokeKey", "info", "start")
 	}
 
-	//wrap the token in a claims
-	signer := jwt.NewWithClaims(jwt.SigningMethodRS256, clms)
-
-	//sign the token
-	tokenString, err := signer.SignedString(sessMgr.kp.GetPriKey())
-	if err != nil {
-		return "", err
+	if err := sessMgr.ring.Revoke(kid); err != nil {
+		return err
 	}
 
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "issueJwt", "info", "end")
+		lblog.LogEvent("SessMgr", "RevokeKey", "info", "end")
 	}
 
-	//return the jwt string
-	return tokenString, nil
+	return nil
 }
 
-//CheckUserRole checks that the jwt authorises a given claim
-func (sessMgr *SessMgr) CheckUserRole(ctx context.Context, sessionID string, roleName string) (bool, error) {
+//NewSession returns a signed jwt as a string
+func (sessMgr *SessMgr) NewSession(ctx context.Context, shdr *SessionClaims) (string, error) {
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "CheckUserRole", "info", "start")
+		lblog.LogEvent("SessMgr", "NewSession", "info", "start")
 	}
 
-	//extract the token
-	signer, err := sessMgr.extractJwt(sessionID)
+	tokenstring, err := sessMgr.issueJwt(ctx, shdr, "", sessMgr.policy.InitialLifetime)
 	if err != nil {
-		return false, err
+		return "", err
 	}
 
-	//add/update the appclaim
-	rle := signer.Claims.(jwt.MapClaims)[ConstJwtRole].(string)
-
-	if sessMgr.checkRoleToken(rle, roleName, sessMgr.bc.GetConfigValue(ctx, "EnvSessAppRoleDelim")) {
-		return true, nil
+	//keep a server-side record of the session if a store is set
+	if sessMgr.sessions != nil {
+		if err := sessMgr.persistCandidate(ctx, shdr); err != nil {
+			return "", err
+		}
 	}
 
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "CheckUserRole", "info", "end")
+		lblog.LogEvent("SessMgr", "NewSession", "info", "end")
 	}
 
-	return false, nil
+	return tokenstring, nil
 }
 
-//GetJwtClaim returns a decoded map[string]interface{} from the session string
-func (sessMgr *SessMgr) GetJwtClaim(ctx context.Context, sessionID string) (map[string]interface{}, error) {
-	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "GetJwtClaim", "info", "start")
+//persistCandidate records the session header as a login candidate
+func (sessMgr *SessMgr) persistCandidate(ctx context.Context, shdr *SessionClaims) error {
+	if shdr == nil || shdr.ID == "" {
+		return ErrLoginSessionNotCreated
 	}
 
-	//extract the token
-	signer, err := sessMgr.extractJwt(sessionID)
+	//the roles are stored as a json list, so role names can hold the delimiter
+	roles, err := json.Marshal(shdr.Roles)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	//add/update the appclaim
-	clm := signer.Claims.(jwt.MapClaims)
+	lc := &LoginCandidate{
+		SessionID:     shdr.ID,
+		UserAccountID: shdr.AccountID,
+		Email:         shdr.Email,
+		RoleToken:     string(roles),
+	}
 
-	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "GetJwtClaim", "info", "end")
+	if err := sessMgr.sessions.CreateCandidate(ctx, lc); err != nil {
+		lblog.LogEvent("SessMgr", "persistCandidate", "error", err.Error())
+		return ErrLoginSessionNotCreated
 	}
 
-	return clm, nil
+	return nil
 }
 
-//GetJwtClaimElement returns a decoded interface{} from the session string
-func (sessMgr *SessMgr) GetJwtClaimElement(ctx context.Context, sessionID, element string) (interface{}, error) {
+//issueJwt adds the jwt claim to the session header and returns the token string
+//the token type is only set for access/refresh token pairs
+func (sessMgr *SessMgr) issueJwt(ctx context.Context, sesshdr *SessionClaims, typ string, lifetime time.Duration) (string, error) {
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "GetJwtClaimElement", "info", "start")
+		lblog.LogEvent("SessMgr", "issueJwt", "info", "start")
 	}
 
-	//extract the token
-	signer, err := sessMgr.extractJwt(sessionID)
-	if err != nil {
-		return nil, err
+	if sesshdr == nil {
+		sesshdr = &SessionClaims{}
 	}
 
-	//get the claim element
-	clm, ok := signer.Claims.(jwt.MapClaims)[element]
+	now := sessMgr.clock.Now()
+
+	//no token outlives the absolute session lifetime
+	exp := sessMgr.policy.expiry(now, lifetime)
 
-	//if it doesn't exist then return error
-	if !ok {
-		return nil, ErrClaimElementNotExist
+	//the manager sets the registered claims, the header supplies the session identity
+	//token pairs also carry their refresh chain (fam, gen)
+	clms := &SessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sesshdr.ID,
+			Issuer:    sessMgr.issuer,
+			Audience:  sessMgr.audience,
+			ExpiresAt: jwt.NewNumericDate(exp),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Roles:      sesshdr.Roles,
+		AccountID:  sesshdr.AccountID,
+		Email:      sesshdr.Email,
+		AuthTime:   jwt.NewNumericDate(now),
+		TokenType:  typ,
+		Family:     sesshdr.Family,
+		Generation: sesshdr.Generation,
+		AppClaims:  sesshdr.clone().AppClaims,
+	}
+
+	//sign the token
+	tokenString, err := sessMgr.signClaims(clms)
+	if err != nil {
+		return "", err
 	}
 
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "GetJwtClaimElement", "info", "end")
+		lblog.LogEvent("SessMgr", "issueJwt", "info", "end")
 	}
 
-	return clm, nil
+	//return the jwt string
+	return tokenString, nil
 }
 
-//IsSessionValid returns a bool indicating if the session is still valid
-func (sessMgr *SessMgr) IsSessionValid(ctx context.Context, sessionID string) (bool, error) {
-	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "IsSessionValid", "info", "start")
+//absoluteExpiry limits exp to the absolute session lifetime measured from the original authentication time
+//tokens issued before auth_time was introduced are stamped with the current time
+func (sessMgr *SessMgr) absoluteExpiry(clms *SessionClaims, exp time.Time) (time.Time, error) {
+	if sessMgr.policy.AbsoluteTimeout <= 0 {
+		return exp, nil
 	}
 
-	//extract action checks jwt validity
-	tk, err := sessMgr.extractJwt(sessionID)
-	if err != nil {
-		return false, err
+	if clms.AuthTime == nil {
+		clms.AuthTime = jwt.NewNumericDate(sessMgr.clock.Now())
 	}
 
-	if !tk.Valid {
-		return false, ErrJwtInvalidSession
+	end := clms.AuthTime.Add(sessMgr.policy.AbsoluteTimeout)
+	if !sessMgr.clock.Now().Before(end) {
+		return time.Time{}, ErrJwtSessionExpired
 	}
 
-	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "IsSessionValid", "info", "end")
+	if exp.After(end) {
+		return end, nil
+	}
+
+	return exp, nil
+}
+
+//signClaims wraps the claims in a token and signs it with the active key of the key ring
+func (sessMgr *SessMgr) signClaims(clms *SessionClaims) (string, error) {
+	sk := sessMgr.ring.signingKey()
+
+	signer := jwt.NewWithClaims(sk.Method, clms)
+	signer.Header["kid"] = sk.KeyID
+
+	return signer.SignedString(sk.SignKey)
+}
+
+//extractOwnJwt converts a signed jwt string issued by this manager to its session claims
+//tokens of trusted issuers can be read, but are not re-signed under our issuer
+func (sessMgr *SessMgr) extractOwnJwt(ctx context.Context, sessionID string) (*SessionClaims, error) {
+	clms, err := sessMgr.extractJwt(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if clms.Issuer != sessMgr.issuer {
+		return nil, ErrJwtIssuer
 	}
 
-	return true, nil
+	return clms, nil
 }
 
 //RefreshSession exchanges a valid token for an extended life token
+//the token is returned unchanged if it is not yet within the refresh-ahead threshold of the session policy
 func (sessMgr *SessMgr) RefreshSession(ctx context.Context, sessionID string) <-chan interface{} {
 	if EnvDebugOn {
 		lblog.LogEvent("SessMgr", "RefreshSession", "info", "start")
@@ -320,9 +405,9 @@ func (sessMgr *SessMgr) RefreshSession(ctx context.Context, sessionID string) <-
 	//create the channels
 	result := make(chan interface{}, 1)
 
-	//mark the time
-	exp := time.Now().Add(time.Minute * time.Duration(sessMgr.extendVal)).Unix()
-	now := time.Now().Unix()
+	//mark the time, each refresh grants the idle timeout
+	mark := sessMgr.clock.Now()
+	exp := mark.Add(sessMgr.policy.IdleTimeout)
 
 	//token renewal function
 	rfn := func(sessid string) {
@@ -331,7 +416,7 @@ func (sessMgr *SessMgr) RefreshSession(ctx context.Context, sessionID string) <-
 		defer wg.Done()
 
 		//extract the token
-		signer, err := sessMgr.extractJwt(sessionID)
+		clms, err := sessMgr.extractOwnJwt(ctx, sessionID)
 
 		//send back the errors if any occur
 		if err != nil {
@@ -339,13 +424,35 @@ func (sessMgr *SessMgr) RefreshSession(ctx context.Context, sessionID string) <-
 			return
 		}
 
+		//access tokens can only be renewed through their refresh token
+		if clms.TokenType == ConstTokenAccess {
+			result <- ErrJwtTokenType
+			return
+		}
+
+		//tokens which are not yet close to expiry are handed back unchanged
+		if clms.ExpiresAt != nil && !sessMgr.policy.dueRefresh(mark, clms.ExpiresAt.Time) {
+			select {
+			case <-ctx.Done():
+			case result <- sessionID:
+			}
+			return
+		}
+
+		//the extension cannot pass the absolute session lifetime
+		limit, err := sessMgr.absoluteExpiry(clms, exp)
+		if err != nil {
+			result <- err
+			return
+		}
+
 		//extend the expiry
-		signer.Claims.(jwt.MapClaims)["exp"] = exp
-		signer.Claims.(jwt.MapClaims)["iat"] = now
-		signer.Claims.(jwt.MapClaims)["nbf"] = now
+		clms.ExpiresAt = jwt.NewNumericDate(limit)
+		clms.IssuedAt = jwt.NewNumericDate(mark)
+		clms.NotBefore = jwt.NewNumericDate(mark)
 
 		//sign the string again
-		tokenString, err := signer.SignedString(sessMgr.kp.GetPriKey())
+		tokenString, err := sessMgr.signClaims(clms)
 
 		//send back the errors if any occur
 		if err != nil {
@@ -376,23 +483,15 @@ func (sessMgr *SessMgr) RefreshSession(ctx context.Context, sessionID string) <-
 	return result
 }
 
-//SetAppClaim adds or updates an appclaim within the jwt (includes token refresh)
+//SetAppClaim adds or updates an appclaim within the jwt (includes token refresh), a reserved claim name returns a ReservedClaimError
 func (sessMgr *SessMgr) SetAppClaim(ctx context.Context, sessionID string, appName string, appClaim string) (string, error) {
 	if EnvDebugOn {
 		lblog.LogEvent("SessMgr", "SetAppClaim", "info", "start")
 	}
 
-	//extract the token
-	signer, err := sessMgr.extractJwt(sessionID)
-	if err != nil {
-		return "", err
-	}
-
-	//add/update the appclaim
-	signer.Claims.(jwt.MapClaims)[appName] = appClaim
-
-	//sign the string again
-	tokenString, err := signer.SignedString(sessMgr.kp.GetPriKey())
+	tokenString, err := sessMgr.ModifyClaims(ctx, sessionID, func(ce *ClaimsEditor) error {
+		return ce.Set(appName, appClaim)
+	})
 	if err != nil {
 		return "", err
 	}
@@ -404,30 +503,56 @@ func (sessMgr *SessMgr) SetAppClaim(ctx context.Context, sessionID string, appNa
 	return tokenString, nil
 }
 
-//DeleteAppClaim removes an appclaim within the jwt (includes token refresh)
+//DeleteAppClaim removes an appclaim within the jwt (includes token refresh), a reserved claim name returns a ReservedClaimError
 func (sessMgr *SessMgr) DeleteAppClaim(ctx context.Context, sessionID string, appName string) (string, error) {
 	if EnvDebugOn {
 		lblog.LogEvent("SessMgr", "DeleteAppClaim", "info", "start")
 	}
 
-	//extract the token
-	signer, err := sessMgr.extractJwt(sessionID)
+	tokenString, err := sessMgr.ModifyClaims(ctx, sessionID, func(ce *ClaimsEditor) error {
+		return ce.Delete(appName)
+	})
 	if err != nil {
 		return "", err
 	}
 
-	//delete the appclaim
-	delete(signer.Claims.(jwt.MapClaims), appName)
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "DeleteAppClaim", "info", "end")
+	}
+
+	return tokenString, nil
+}
+
+//RevokeSession invalidates the session before it expires
+//either token of a token pair can be revoked, so a client which only holds its refresh token can still log out
+func (sessMgr *SessMgr) RevokeSession(ctx context.Context, sessionID string) error {
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "RevokeSession", "info", "start")
+	}
 
-	//sign the string again
-	tokenString, err := signer.SignedString(sessMgr.kp.GetPriKey())
+	//extract the token of any type
+	clms, err := sessMgr.parseJwt(ctx, sessionID)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	if clms.ID == "" || clms.ExpiresAt == nil {
+		return ErrClaimElementNotExist
+	}
+
+	//the jti is shared with the refresh token of a token pair, which can outlive the token being revoked
+	until := clms.ExpiresAt.Time
+	if pairExp := sessMgr.clock.Now().Add(time.Minute * time.Duration(sessMgr.refreshVal)); pairExp.After(until) {
+		until = pairExp
+	}
+
+	if err := sessMgr.revocations.Revoke(ctx, clms.ID, until); err != nil {
+		return err
 	}
 
 	if EnvDebugOn {
-		lblog.LogEvent("SessMgr", "DeleteAppClaim", "info", "end")
+		lblog.LogEvent("SessMgr", "RevokeSession", "info", "end")
 	}
 
-	return tokenString, nil
+	return nil
 }
@@ -10,7 +10,7 @@ import (
 	"golang.org/x/net/context"
 )
 
-func createNewSess(ctx context.Context) (SessProvider, error) {
+func createNewSess(ctx context.Context, opts ...Option) (SessProvider, error) {
 	bc := lbcf.NewConfig(ctx)
 
 	//create a keypair
@@ -19,19 +19,19 @@ func createNewSess(ctx context.Context) (SessProvider, error) {
 		return nil, err
 	}
 
-	sm1, err := NewMgr(ctx, bc, kpr)
+	sm1, err := NewMgr(ctx, bc, kpr, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	return sm1, nil
 }
-func createBaseMap() map[string]interface{} {
-	shdr := make(map[string]interface{})
-	shdr[ConstJwtID] = "dummyUser1SessId"
-	shdr[ConstJwtRole] = "testapp1:testapp2"
-	shdr[ConstJwtAccID] = "dummyUser1"
-	shdr[ConstJwtEml] = "session@sessiontest.com"
+func createBaseClaims() *SessionClaims {
+	shdr := &SessionClaims{}
+	shdr.ID = "dummyUser1SessId"
+	shdr.Roles = []string{"testapp1", "testapp2"}
+	shdr.AccountID = "dummyUser1"
+	shdr.Email = "session@sessiontest.com"
 
 	return shdr
 }
@@ -55,7 +55,7 @@ func Test_NewSession(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	shdr := createBaseMap()
+	shdr := createBaseClaims()
 
 	sess, err := sm1.NewSession(ctx, shdr)
 	if err != nil {
@@ -76,7 +76,7 @@ func Test_CheckUserRole(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	shdr := createBaseMap()
+	shdr := createBaseClaims()
 
 	sess, err := sm1.NewSession(ctx, shdr)
 	if err != nil {
@@ -113,7 +113,7 @@ func Test_IsValid(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	shdr := createBaseMap()
+	shdr := createBaseClaims()
 
 	sess, err := sm1.NewSession(ctx, shdr)
 	if err != nil {
@@ -143,7 +143,7 @@ func Test_GetSessionHeader(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	shdr := createBaseMap()
+	shdr := createBaseClaims()
 
 	sess, err := sm1.NewSession(ctx, shdr)
 	if err != nil {
@@ -161,23 +161,23 @@ func Test_GetSessionHeader(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	t.Logf("Session header useraccount: %s", shdr1["aid"].(string))
-	t.Logf("Session header sessionid: %s", shdr1["jti"].(string))
-	t.Logf("Session header roletoken: %s", shdr1["rle"].(string))
-	t.Logf("Session header email: %s", shdr1["eml"].(string))
-	t.Logf("Session header claims: %v", shdr1["clm"])
+	t.Logf("Session header useraccount: %s", shdr1.AccountID)
+	t.Logf("Session header sessionid: %s", shdr1.ID)
+	t.Logf("Session header roles: %v", shdr1.Roles)
+	t.Logf("Session header email: %s", shdr1.Email)
+	t.Logf("Session header claims: %v", shdr1.AppClaims)
 }
 func Test_RefreshSession(t *testing.T) {
-	//Note: this contains a 1 second wait to simulate elapsed user time between api calls
-	//Remember to factor this in when benchmarking execution speeds
 	ctx := context.Background()
 
-	sm1, err := createNewSess(ctx)
+	clk := newTestClock()
+
+	sm1, err := createNewSess(ctx, WithClock(clk))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	shdr := createBaseMap()
+	shdr := createBaseClaims()
 
 	sess, err := sm1.NewSession(ctx, shdr)
 	if err != nil {
@@ -191,7 +191,7 @@ func Test_RefreshSession(t *testing.T) {
 	t.Logf("Session string (jwt): %s", sess)
 
 	//this simulates time elapsed on the client side
-	time.Sleep(1 * time.Second)
+	clk.Advance(1 * time.Second)
 
 	//this would need to be declared
 	var (
@@ -240,7 +240,7 @@ func Test_SetAppClaim(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	shdr := createBaseMap()
+	shdr := createBaseClaims()
 
 	sess, err := sm1.NewSession(ctx, shdr)
 	if err != nil {
@@ -276,7 +276,7 @@ func Test_DeleteAppClaim(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	shdr := createBaseMap()
+	shdr := createBaseClaims()
 
 	sess, err := sm1.NewSession(ctx, shdr)
 	if err != nil {
@@ -330,7 +330,7 @@ func Test_UpdateAppClaim(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	shdr := createBaseMap()
+	shdr := createBaseClaims()
 
 	sess, err := sm1.NewSession(ctx, shdr)
 	if err != nil {
@@ -388,3 +388,30 @@ func Test_UpdateAppClaim(t *testing.T) {
 
 	t.Fatal("Updated claim did not set correctly")
 }
+func Test_RevokeSession(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sm1.RevokeSession(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sm1.IsSessionValid(ctx, sess)
+	if err != ErrJwtRevoked {
+		t.Fatalf("expected revoked session error, got %v", err)
+	}
+
+	_, err = sm1.GetJwtClaim(ctx, sess)
+	if err != ErrJwtRevoked {
+		t.Fatalf("expected revoked session error, got %v", err)
+	}
+}
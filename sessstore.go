@@ -0,0 +1,127 @@
+package session
+
+import (
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+//SessionStore persists the server-side record (LoginCandidate) of each issued session
+type SessionStore interface {
+	CreateCandidate(ctx context.Context, lc *LoginCandidate) error
+	GetCandidate(ctx context.Context, sessionID string) (*LoginCandidate, error)
+	ActivateCandidate(ctx context.Context, sessionID string) (*LoginCandidate, error)
+	ListCandidates(ctx context.Context, userAccountID string) ([]*LoginCandidate, error)
+	DeleteCandidate(ctx context.Context, sessionID string) error
+}
+
+//MemSessionStore is an in-memory SessionStore
+type MemSessionStore struct {
+	mux        sync.Mutex
+	candidates map[string]LoginCandidate
+	clock      Clock
+}
+
+//NewMemSessionStore creates an empty in-memory session store
+func NewMemSessionStore() *MemSessionStore {
+	return &MemSessionStore{candidates: make(map[string]LoginCandidate), clock: systemClock{}}
+}
+
+//setClock sets the clock used to date login candidates
+func (ss *MemSessionStore) setClock(c Clock) {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	ss.clock = c
+}
+
+//CreateCandidate stores a new login candidate
+func (ss *MemSessionStore) CreateCandidate(ctx context.Context, lc *LoginCandidate) error {
+	if lc == nil || lc.SessionID == "" {
+		return ErrLoginCandidateInvalid
+	}
+
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	if _, ok := ss.candidates[lc.SessionID]; ok {
+		return ErrLoginCandidateExists
+	}
+
+	if lc.CreatedDate == nil {
+		now := ss.clock.Now().UTC()
+		lc.CreatedDate = &now
+	}
+
+	ss.candidates[lc.SessionID] = *lc
+
+	return nil
+}
+
+//GetCandidate returns the login candidate for the session id
+func (ss *MemSessionStore) GetCandidate(ctx context.Context, sessionID string) (*LoginCandidate, error) {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	lc, ok := ss.candidates[sessionID]
+	if !ok {
+		return nil, ErrLoginCandidateNotExist
+	}
+
+	return &lc, nil
+}
+
+//ActivateCandidate marks the login candidate as activated
+func (ss *MemSessionStore) ActivateCandidate(ctx context.Context, sessionID string) (*LoginCandidate, error) {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	lc, ok := ss.candidates[sessionID]
+	if !ok {
+		return nil, ErrLoginCandidateNotExist
+	}
+
+	now := ss.clock.Now().UTC()
+	lc.Activated = true
+	lc.ActivatedDate = &now
+
+	ss.candidates[sessionID] = lc
+
+	return &lc, nil
+}
+
+//ListCandidates returns the login candidates of the user account, oldest first
+func (ss *MemSessionStore) ListCandidates(ctx context.Context, userAccountID string) ([]*LoginCandidate, error) {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	var lcs []*LoginCandidate
+
+	for _, lc := range ss.candidates {
+		if lc.UserAccountID == userAccountID {
+			item := lc
+			lcs = append(lcs, &item)
+		}
+	}
+
+	sort.Slice(lcs, func(i, j int) bool {
+		return lcs[i].CreatedDate.Before(*lcs[j].CreatedDate)
+	})
+
+	return lcs, nil
+}
+
+//DeleteCandidate removes the login candidate for the session id
+func (ss *MemSessionStore) DeleteCandidate(ctx context.Context, sessionID string) error {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	if _, ok := ss.candidates[sessionID]; !ok {
+		return ErrLoginCandidateNotExist
+	}
+
+	delete(ss.candidates, sessionID)
+
+	return nil
+}
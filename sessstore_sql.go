@@ -0,0 +1,187 @@
+package session
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+//SQLSessionStore is a database/sql SessionStore, for drivers which use ? placeholders
+type SQLSessionStore struct {
+	db    *sql.DB
+	table string
+	clock Clock
+}
+
+//tableNamePattern restricts table names to plain identifiers, as the name is written into the sql
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//NewSQLSessionStore creates a session store which keeps login candidates in the table
+func NewSQLSessionStore(db *sql.DB, table string) (*SQLSessionStore, error) {
+	if !tableNamePattern.MatchString(table) {
+		return nil, ErrTableNameInvalid
+	}
+
+	return &SQLSessionStore{db: db, table: table, clock: systemClock{}}, nil
+}
+
+//setClock sets the clock used to date login candidates
+func (ss *SQLSessionStore) setClock(c Clock) {
+	ss.clock = c
+}
+
+//CreateTable creates the login candidate table if it does not exist
+func (ss *SQLSessionStore) CreateTable(ctx context.Context) error {
+	_, err := ss.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		sessionid VARCHAR(255) NOT NULL PRIMARY KEY,
+		useraccountid VARCHAR(255) NOT NULL,
+		email VARCHAR(255) NOT NULL,
+		roletoken TEXT NOT NULL,
+		activated BOOLEAN NOT NULL,
+		createddate TIMESTAMP NULL,
+		activateddate TIMESTAMP NULL
+	)`, ss.table))
+	if err != nil {
+		return err
+	}
+
+	_, err = ss.db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_useraccountid ON %s (useraccountid)", ss.table, ss.table))
+
+	return err
+}
+
+//CreateCandidate stores a new login candidate
+func (ss *SQLSessionStore) CreateCandidate(ctx context.Context, lc *LoginCandidate) error {
+	if lc == nil || lc.SessionID == "" {
+		return ErrLoginCandidateInvalid
+	}
+
+	if lc.CreatedDate == nil {
+		now := ss.clock.Now().UTC()
+		lc.CreatedDate = &now
+	}
+
+	_, err := ss.db.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (sessionid, useraccountid, email, roletoken, activated, createddate, activateddate) VALUES (?, ?, ?, ?, ?, ?, ?)", ss.table),
+		lc.SessionID, lc.UserAccountID, lc.Email, lc.RoleToken, lc.Activated, nullTime(lc.CreatedDate), nullTime(lc.ActivatedDate))
+	if err != nil {
+		//constraint errors differ by driver, a failed insert of a session id which exists is a duplicate
+		if _, gerr := ss.GetCandidate(ctx, lc.SessionID); gerr == nil {
+			return ErrLoginCandidateExists
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+//GetCandidate returns the login candidate for the session id
+func (ss *SQLSessionStore) GetCandidate(ctx context.Context, sessionID string) (*LoginCandidate, error) {
+	row := ss.db.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT sessionid, useraccountid, email, roletoken, activated, createddate, activateddate FROM %s WHERE sessionid = ?", ss.table),
+		sessionID)
+
+	lc, err := scanCandidate(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrLoginCandidateNotExist
+	}
+
+	return lc, err
+}
+
+//ActivateCandidate marks the login candidate as activated
+func (ss *SQLSessionStore) ActivateCandidate(ctx context.Context, sessionID string) (*LoginCandidate, error) {
+	now := ss.clock.Now().UTC()
+
+	res, err := ss.db.ExecContext(ctx,
+		fmt.Sprintf("UPDATE %s SET activated = ?, activateddate = ? WHERE sessionid = ?", ss.table),
+		true, now, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrLoginCandidateNotExist
+	}
+
+	return ss.GetCandidate(ctx, sessionID)
+}
+
+//ListCandidates returns the login candidates of the user account, oldest first
+func (ss *SQLSessionStore) ListCandidates(ctx context.Context, userAccountID string) ([]*LoginCandidate, error) {
+	rows, err := ss.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT sessionid, useraccountid, email, roletoken, activated, createddate, activateddate FROM %s WHERE useraccountid = ? ORDER BY createddate", ss.table),
+		userAccountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lcs []*LoginCandidate
+
+	for rows.Next() {
+		lc, err := scanCandidate(rows)
+		if err != nil {
+			return nil, err
+		}
+		lcs = append(lcs, lc)
+	}
+
+	return lcs, rows.Err()
+}
+
+//DeleteCandidate removes the login candidate for the session id
+func (ss *SQLSessionStore) DeleteCandidate(ctx context.Context, sessionID string) error {
+	res, err := ss.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE sessionid = ?", ss.table), sessionID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrLoginCandidateNotExist
+	}
+
+	return nil
+}
+
+//scanCandidate reads a login candidate from a row
+func scanCandidate(row interface{ Scan(...interface{}) error }) (*LoginCandidate, error) {
+	var (
+		lc                 LoginCandidate
+		created, activated sql.NullTime
+	)
+
+	if err := row.Scan(&lc.SessionID, &lc.UserAccountID, &lc.Email, &lc.RoleToken, &lc.Activated, &created, &activated); err != nil {
+		return nil, err
+	}
+
+	if created.Valid {
+		lc.CreatedDate = &created.Time
+	}
+
+	if activated.Valid {
+		lc.ActivatedDate = &activated.Time
+	}
+
+	return &lc, nil
+}
+
+//nullTime converts an optional time for storage
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: *t, Valid: true}
+}
@@ -0,0 +1,166 @@
+package session
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+
+	_ "modernc.org/sqlite"
+)
+
+func createSQLSessionStore(t *testing.T, ctx context.Context) *SQLSessionStore {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//an in-memory sqlite database only lives as long as its connection
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	ss, err := NewSQLSessionStore(db, "logincandidate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ss.CreateTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return ss
+}
+func testSessionStore(t *testing.T, ctx context.Context, ss SessionStore) {
+	for _, id := range []string{"sess1", "sess2"} {
+		if err := ss.CreateCandidate(ctx, &LoginCandidate{SessionID: id, UserAccountID: "dummyUser1", Email: "session@sessiontest.com", RoleToken: "testapp1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ss.CreateCandidate(ctx, &LoginCandidate{SessionID: "sess1", UserAccountID: "dummyUser1"}); err != ErrLoginCandidateExists {
+		t.Fatalf("expected duplicate candidate error, got %v", err)
+	}
+
+	lc, err := ss.GetCandidate(ctx, "sess1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lc.Activated || lc.CreatedDate == nil || lc.ActivatedDate != nil || lc.Email != "session@sessiontest.com" {
+		t.Fatalf("unexpected candidate %v", lc)
+	}
+
+	lc, err = ss.ActivateCandidate(ctx, "sess1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lc.Activated || lc.ActivatedDate == nil {
+		t.Fatal("candidate should be activated")
+	}
+
+	lcs, err := ss.ListCandidates(ctx, "dummyUser1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lcs) != 2 {
+		t.Fatalf("expected 2 candidates, got %d", len(lcs))
+	}
+
+	if err := ss.DeleteCandidate(ctx, "sess1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ss.GetCandidate(ctx, "sess1"); err != ErrLoginCandidateNotExist {
+		t.Fatalf("expected missing candidate error, got %v", err)
+	}
+
+	if _, err := ss.ActivateCandidate(ctx, "sess1"); err != ErrLoginCandidateNotExist {
+		t.Fatalf("expected missing candidate error, got %v", err)
+	}
+
+	if err := ss.DeleteCandidate(ctx, "sess1"); err != ErrLoginCandidateNotExist {
+		t.Fatalf("expected missing candidate error, got %v", err)
+	}
+}
+func Test_MemSessionStore(t *testing.T) {
+	testSessionStore(t, context.Background(), NewMemSessionStore())
+}
+func Test_SQLSessionStore(t *testing.T) {
+	ctx := context.Background()
+
+	ss := createSQLSessionStore(t, ctx)
+
+	testSessionStore(t, ctx, ss)
+
+	//concurrent creates of the same session id only store one candidate
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- ss.CreateCandidate(ctx, &LoginCandidate{SessionID: "race1", UserAccountID: "dummyUser1"})
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			created++
+		case ErrLoginCandidateExists:
+		default:
+			t.Fatalf("expected duplicate candidate error, got %v", err)
+		}
+	}
+
+	if created != 1 {
+		t.Fatalf("expected one candidate to be created, got %d", created)
+	}
+
+	for _, table := range []string{"", "1candidate", "candidate; DROP TABLE x", "schema.candidate"} {
+		if _, err := NewSQLSessionStore(nil, table); err != ErrTableNameInvalid {
+			t.Fatalf("table %q: expected %v, got %v", table, ErrTableNameInvalid, err)
+		}
+	}
+}
+func Test_NewSessionPersistsCandidate(t *testing.T) {
+	ctx := context.Background()
+
+	ss := createSQLSessionStore(t, ctx)
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"], WithSessionStore(ss))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shdr := createBaseClaims()
+	shdr.Roles = append(shdr.Roles, "billing:read")
+
+	if _, err := sm1.NewSession(ctx, shdr); err != nil {
+		t.Fatal(err)
+	}
+
+	lc, err := ss.GetCandidate(ctx, "dummyUser1SessId")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lc.UserAccountID != "dummyUser1" || lc.RoleToken != `["testapp1","testapp2","billing:read"]` {
+		t.Fatalf("unexpected candidate %v", lc)
+	}
+
+	//the same session id cannot be issued twice
+	if _, err := sm1.NewSession(ctx, createBaseClaims()); err != ErrLoginSessionNotCreated {
+		t.Fatalf("expected session not created error, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+
+	kp "github.com/lidstromberg/keypair"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//minHMACKeyLen is the minimum secret length accepted for HMAC signing keys
+const minHMACKeyLen = 32
+
+//SigningKey pairs a jwt signing method with the keys used to sign and verify tokens
+type SigningKey struct {
+	KeyID     string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+//Alg returns the jwt algorithm name of the signing key
+func (sk *SigningKey) Alg() string {
+	return sk.Method.Alg()
+}
+
+//NewRSAKey returns an RS256 signing key from a keypair
+func NewRSAKey(kpr *kp.KeyPair) (*SigningKey, error) {
+	if kpr == nil || kpr.GetPriKey() == nil || kpr.GetPubKey() == nil {
+		return nil, ErrKeyPairNotExist
+	}
+
+	return &SigningKey{
+		Method:    jwt.SigningMethodRS256,
+		SignKey:   kpr.GetPriKey(),
+		VerifyKey: kpr.GetPubKey(),
+	}, nil
+}
+
+//NewRSAPSSKey returns a PS256 signing key from an rsa private key
+func NewRSAPSSKey(pri *rsa.PrivateKey) (*SigningKey, error) {
+	if pri == nil {
+		return nil, ErrKeyPairNotExist
+	}
+
+	return &SigningKey{
+		Method:    jwt.SigningMethodPS256,
+		SignKey:   pri,
+		VerifyKey: &pri.PublicKey,
+	}, nil
+}
+
+//NewECDSAKey returns an ES256 or ES384 signing key, depending on the curve of the private key
+func NewECDSAKey(pri *ecdsa.PrivateKey) (*SigningKey, error) {
+	if pri == nil {
+		return nil, ErrKeyPairNotExist
+	}
+
+	var method jwt.SigningMethod
+
+	switch pri.Curve {
+	case elliptic.P256():
+		method = jwt.SigningMethodES256
+	case elliptic.P384():
+		method = jwt.SigningMethodES384
+	default:
+		return nil, ErrSigningKeyNotSupported
+	}
+
+	return &SigningKey{
+		Method:    method,
+		SignKey:   pri,
+		VerifyKey: &pri.PublicKey,
+	}, nil
+}
+
+//NewEdDSAKey returns an EdDSA (Ed25519) signing key
+func NewEdDSAKey(pri ed25519.PrivateKey) (*SigningKey, error) {
+	if len(pri) != ed25519.PrivateKeySize {
+		return nil, ErrKeyPairNotExist
+	}
+
+	return &SigningKey{
+		Method:    jwt.SigningMethodEdDSA,
+		SignKey:   pri,
+		VerifyKey: pri.Public(),
+	}, nil
+}
+
+//NewHMACKey returns an HS256 signing key from a shared secret
+func NewHMACKey(secret []byte) (*SigningKey, error) {
+	if len(secret) < minHMACKeyLen {
+		return nil, ErrSigningKeyNotSupported
+	}
+
+	return &SigningKey{
+		Method:    jwt.SigningMethodHS256,
+		SignKey:   secret,
+		VerifyKey: secret,
+	}, nil
+}
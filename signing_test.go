@@ -0,0 +1,123 @@
+package session
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"golang.org/x/net/context"
+)
+
+func createTestKeys(t *testing.T) map[string]*SigningKey {
+	rsk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecsk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecsk384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edsk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make(map[string]*SigningKey)
+
+	if keys["PS256"], err = NewRSAPSSKey(rsk); err != nil {
+		t.Fatal(err)
+	}
+	if keys["ES256"], err = NewECDSAKey(ecsk); err != nil {
+		t.Fatal(err)
+	}
+	if keys["ES384"], err = NewECDSAKey(ecsk384); err != nil {
+		t.Fatal(err)
+	}
+	if keys["EdDSA"], err = NewEdDSAKey(edsk); err != nil {
+		t.Fatal(err)
+	}
+	if keys["HS256"], err = NewHMACKey([]byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+func Test_SigningAlgorithms(t *testing.T) {
+	ctx := context.Background()
+
+	for alg, sk := range createTestKeys(t) {
+		if sk.Alg() != alg {
+			t.Fatalf("expected %s signing key, got %s", alg, sk.Alg())
+		}
+
+		sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sess, err := sm1.NewSession(ctx, createBaseClaims())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chk, err := sm1.IsSessionValid(ctx, sess)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !chk {
+			t.Fatalf("%s session string (jwt) should be valid", alg)
+		}
+	}
+}
+func Test_SigningAlgorithmAllowlist(t *testing.T) {
+	ctx := context.Background()
+
+	keys := createTestKeys(t)
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), keys["HS256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sm2, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), keys["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm2.IsSessionValid(ctx, sess); err == nil {
+		t.Fatal("HS256 session string (jwt) should be rejected by an ES256 manager")
+	}
+
+	_, err = NewMgrWithKey(ctx, lbcf.NewConfig(ctx), keys["ES256"], WithAllowedAlgs("RS256"))
+	if err != ErrJwtAlgNotAllowed {
+		t.Fatal("manager should not be created if the signing algorithm is not allowed")
+	}
+
+	_, err = NewMgrWithKey(ctx, lbcf.NewConfig(ctx), keys["ES256"], WithAllowedAlgs("ES256", "none"))
+	if err != ErrJwtAlgNotAllowed {
+		t.Fatal("manager should not be created if the none algorithm is allowed")
+	}
+}
+func Test_NewHMACKeyTooShort(t *testing.T) {
+	if _, err := NewHMACKey([]byte("short")); err != ErrSigningKeyNotSupported {
+		t.Fatal("short hmac secret should be rejected")
+	}
+}
@@ -0,0 +1,170 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"golang.org/x/net/context"
+
+	lblog "github.com/lidstromberg/log"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//NewSessionPair returns a short-lived access token and a long-lived refresh token for the session header
+func (sessMgr *SessMgr) NewSessionPair(ctx context.Context, shdr *SessionClaims) (*TokenPair, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "NewSessionPair", "info", "start")
+	}
+
+	fam, err := newFamilyID()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sessMgr.families.CreateFamily(ctx, fam, sessMgr.clock.Now().Add(time.Minute*time.Duration(sessMgr.refreshVal))); err != nil {
+		return nil, err
+	}
+
+	//both tokens start the refresh chain of a new token family
+	clms := &SessionClaims{}
+	if shdr != nil {
+		clms = shdr.clone()
+	}
+	clms.Family = fam
+	clms.Generation = 0
+
+	pair, err := sessMgr.issuePair(ctx, clms)
+	if err != nil {
+		return nil, err
+	}
+
+	//keep a server-side record of the session if a store is set
+	if sessMgr.sessions != nil {
+		if err := sessMgr.persistCandidate(ctx, shdr); err != nil {
+			return nil, err
+		}
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "NewSessionPair", "info", "end")
+	}
+
+	return pair, nil
+}
+
+//RefreshSessionPair exchanges a valid refresh token for a new access and refresh token pair
+func (sessMgr *SessMgr) RefreshSessionPair(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "RefreshSessionPair", "info", "start")
+	}
+
+	//only refresh tokens are accepted
+	clms, err := sessMgr.extractRefreshJwt(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	//refresh tokens of trusted issuers are rotated by their own issuer
+	if clms.Issuer != sessMgr.issuer {
+		return nil, ErrJwtIssuer
+	}
+
+	//rotate the refresh token, tokens issued before rotation was introduced have no family
+	if fam := clms.Family; fam != "" {
+		advanced, err := sessMgr.families.AdvanceFamily(ctx, fam, clms.Generation, sessMgr.clock.Now().Add(time.Minute*time.Duration(sessMgr.refreshVal)))
+		if err != nil {
+			return nil, err
+		}
+
+		//an old generation means the refresh token was used twice, so the whole family is compromised
+		if !advanced {
+			lblog.LogEvent("SessMgr", "RefreshSessionPair", "warning", "refresh token reuse detected for family "+fam)
+
+			if err := sessMgr.families.RevokeFamily(ctx, fam); err != nil {
+				return nil, err
+			}
+
+			return nil, ErrJwtRefreshReused
+		}
+
+		clms.Generation++
+	}
+
+	pair, err := sessMgr.reissuePair(clms)
+	if err != nil {
+		return nil, err
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("SessMgr", "RefreshSessionPair", "info", "end")
+	}
+
+	return pair, nil
+}
+
+//issuePair issues an access and refresh token for the session header
+func (sessMgr *SessMgr) issuePair(ctx context.Context, shdr *SessionClaims) (*TokenPair, error) {
+	access, err := sessMgr.issueJwt(ctx, shdr, ConstTokenAccess, time.Minute*time.Duration(sessMgr.accessVal))
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := sessMgr.issueJwt(ctx, shdr, ConstTokenRefresh, time.Minute*time.Duration(sessMgr.refreshVal))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+//newFamilyID returns a random token family id
+func newFamilyID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+//reissuePair signs a new token pair carrying the claims of an existing token
+func (sessMgr *SessMgr) reissuePair(clms *SessionClaims) (*TokenPair, error) {
+	now := sessMgr.clock.Now()
+
+	//neither token can pass the absolute session lifetime
+	accessExp, err := sessMgr.absoluteExpiry(clms, now.Add(time.Minute*time.Duration(sessMgr.accessVal)))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshExp, err := sessMgr.absoluteExpiry(clms, now.Add(time.Minute*time.Duration(sessMgr.refreshVal)))
+	if err != nil {
+		return nil, err
+	}
+
+	access := clms.clone()
+	access.TokenType = ConstTokenAccess
+	access.ExpiresAt = jwt.NewNumericDate(accessExp)
+	access.IssuedAt = jwt.NewNumericDate(now)
+	access.NotBefore = jwt.NewNumericDate(now)
+
+	refresh := clms.clone()
+	refresh.TokenType = ConstTokenRefresh
+	refresh.ExpiresAt = jwt.NewNumericDate(refreshExp)
+	refresh.IssuedAt = jwt.NewNumericDate(now)
+	refresh.NotBefore = jwt.NewNumericDate(now)
+
+	accessString, err := sessMgr.signClaims(access)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshString, err := sessMgr.signClaims(refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: accessString, RefreshToken: refreshString}, nil
+}
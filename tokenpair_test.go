@@ -0,0 +1,207 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"golang.org/x/net/context"
+)
+
+func Test_NewSessionPair(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := sm1.NewSessionPair(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, pair.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+
+	typ, err := sm1.GetJwtClaimElement(ctx, pair.AccessToken, ConstJwtType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if typ.(string) != ConstTokenAccess {
+		t.Fatalf("expected access token type, got %v", typ)
+	}
+
+	//a refresh token is not a credential
+	if _, err := sm1.IsSessionValid(ctx, pair.RefreshToken); err != ErrJwtTokenType {
+		t.Fatalf("expected token type error, got %v", err)
+	}
+
+	//an access token cannot be used to refresh
+	if _, err := sm1.RefreshSessionPair(ctx, pair.AccessToken); err != ErrJwtTokenType {
+		t.Fatalf("expected token type error, got %v", err)
+	}
+
+	r, ok := <-sm1.RefreshSession(ctx, pair.AccessToken)
+	if !ok || r != ErrJwtTokenType {
+		t.Fatalf("expected token type error, got %v", r)
+	}
+}
+func Test_RefreshSessionPair(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := sm1.NewSessionPair(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair2, err := sm1.RefreshSessionPair(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aid, err := sm1.GetJwtClaimElement(ctx, pair2.AccessToken, ConstJwtAccID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if aid.(string) != "dummyUser1" {
+		t.Fatal("refreshed access token lost the account id")
+	}
+
+	if _, err := sm1.RefreshSessionPair(ctx, pair2.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	//revoking the session also revokes its refresh token
+	if err := sm1.RevokeSession(ctx, pair2.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.RefreshSessionPair(ctx, pair2.RefreshToken); err != ErrJwtRevoked {
+		t.Fatalf("expected revoked session error, got %v", err)
+	}
+}
+func Test_RevokeSessionPairByRefreshToken(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := sm1.NewSessionPair(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a client which only holds its refresh token can still log out
+	if err := sm1.RevokeSession(ctx, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, pair.AccessToken); err != ErrJwtRevoked {
+		t.Fatalf("expected revoked session error, got %v", err)
+	}
+
+	if _, err := sm1.RefreshSessionPair(ctx, pair.RefreshToken); err != ErrJwtRevoked {
+		t.Fatalf("expected revoked session error, got %v", err)
+	}
+}
+func Test_RefreshTokenReuse(t *testing.T) {
+	ctx := context.Background()
+
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := sm1.NewSessionPair(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair2, err := sm1.RefreshSessionPair(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen, err := sm1.GetJwtClaimElement(ctx, pair2.AccessToken, ConstJwtGeneration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gen.(int64) != 1 {
+		t.Fatalf("expected generation 1, got %v", gen)
+	}
+
+	//presenting the rotated refresh token again revokes the whole family
+	if _, err := sm1.RefreshSessionPair(ctx, pair.RefreshToken); err != ErrJwtRefreshReused {
+		t.Fatalf("expected refresh reuse error, got %v", err)
+	}
+
+	if _, err := sm1.RefreshSessionPair(ctx, pair2.RefreshToken); err != ErrJwtRevoked {
+		t.Fatalf("expected revoked session error, got %v", err)
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, pair2.AccessToken); err != ErrJwtRevoked {
+		t.Fatalf("expected revoked session error, got %v", err)
+	}
+}
+func Test_AbsoluteSessionLifetime(t *testing.T) {
+	ctx := context.Background()
+
+	bc := lbcf.NewConfig(ctx)
+
+	sm1, err := NewMgrWithKey(ctx, bc, createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := sm1.NewSessionPair(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clms1, err := sm1.GetJwtClaim(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err = sm1.RefreshSessionPair(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.SetAppClaim(ctx, pair.AccessToken, "testapp1.editor", "ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clms2, err := sm1.GetJwtClaim(ctx, sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !clms2.AuthTime.Equal(clms1.AuthTime.Time) {
+		t.Fatal("auth_time should be kept through refresh and app claim changes")
+	}
+
+	//simulate a session which authenticated beyond the absolute lifetime
+	sm1.policy.AbsoluteTimeout = time.Minute
+	clms := &SessionClaims{AuthTime: jwt.NewNumericDate(time.Now().Add(-2 * time.Minute))}
+
+	if _, err := sm1.reissuePair(clms); err != ErrJwtSessionExpired {
+		t.Fatalf("expected session expired error, got %v", err)
+	}
+}
@@ -0,0 +1,527 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+
+	lbcf "github.com/lidstromberg/config"
+	lblog "github.com/lidstromberg/log"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//KeySet provides the keys used to verify tokens
+type KeySet interface {
+	VerificationKey(kid, alg string) (interface{}, error)
+	Algs() []string
+}
+
+//Verifier validates and reads jwts without holding any signing material
+type Verifier struct {
+	keys        KeySet
+	algs        []string
+	revocations RevocationStore
+	families    FamilyStore
+	roles       roleHierarchy
+	evaluator   PolicyEvaluator
+	audience    []string
+	issuer      string
+	trusted     map[string]KeySet
+	clock       Clock
+	leeway      time.Duration
+	bc          lbcf.ConfigSetting
+}
+
+//SessVerifier defines the read operations of a session manager
+type SessVerifier interface {
+	CheckUserRole(ctx context.Context, sessionID string, roleName string) (bool, error)
+	HasAnyRole(ctx context.Context, sessionID string, roleNames ...string) (bool, error)
+	HasAllRoles(ctx context.Context, sessionID string, roleNames ...string) (bool, error)
+	HoldsAllRoles(clms *SessionClaims, roleNames ...string) bool
+	Authorize(ctx context.Context, sessionID, action, resource string) (*Decision, error)
+	GetJwtClaim(ctx context.Context, sessionID string) (*SessionClaims, error)
+	GetJwtClaimElement(ctx context.Context, sessionID, element string) (interface{}, error)
+	IsSessionValid(ctx context.Context, sessionID string) (bool, error)
+}
+
+//NewVerifier creates a verifier which validates tokens against the key set
+func NewVerifier(ctx context.Context, bc lbcf.ConfigSetting, keys KeySet, opts ...Option) (*Verifier, error) {
+	preflight(ctx, bc)
+
+	return newVerifier(ctx, bc, keys, newSettings(opts))
+}
+
+//NewVerifierFromJWKS creates a verifier which validates tokens against a jwks document
+func NewVerifierFromJWKS(ctx context.Context, bc lbcf.ConfigSetting, data []byte, opts ...Option) (*Verifier, error) {
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewVerifier(ctx, bc, keys, opts...)
+}
+
+//NewVerifierFromFile creates a verifier which validates tokens against a jwks file
+func NewVerifierFromFile(ctx context.Context, bc lbcf.ConfigSetting, path string, opts ...Option) (*Verifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewVerifierFromJWKS(ctx, bc, data, opts...)
+}
+
+//NewVerifierFromURL creates a verifier which validates tokens against a remote jwks, refetched after the refresh interval
+func NewVerifierFromURL(ctx context.Context, bc lbcf.ConfigSetting, url string, refresh time.Duration, opts ...Option) (*Verifier, error) {
+	keys := NewRemoteKeySet(url, refresh)
+
+	//fail early if the jwks cannot be fetched
+	if err := keys.load(ctx); err != nil {
+		return nil, err
+	}
+
+	return NewVerifier(ctx, bc, keys, opts...)
+}
+
+//newVerifier creates the verifier once the config has passed preflight
+func newVerifier(ctx context.Context, bc lbcf.ConfigSetting, keys KeySet, st *settings) (*Verifier, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "newVerifier", "info", "start")
+	}
+
+	if keys == nil {
+		return nil, ErrKeyPairNotExist
+	}
+
+	//the role hierarchy comes from config unless one is set, either way it is checked for cycles here
+	var roles roleHierarchy
+	var err error
+
+	if st.roles != nil {
+		roles, err = newRoleHierarchy(st.roles)
+	} else {
+		roles, err = newConfigRoleHierarchy(ctx, bc)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//the audience comes from config unless one is set
+	if st.audience == nil {
+		st.audience = optionalConfigList(ctx, bc, "EnvSessAudience")
+	}
+
+	//tokens of our own issuer are always verified against our own keys
+	issuer := bc.GetConfigValue(ctx, "EnvSessTokenIssuer")
+
+	for iss, ks := range st.trusted {
+		if iss == "" || iss == issuer {
+			return nil, ErrJwtIssuer
+		}
+
+		if ks == nil {
+			return nil, ErrKeyPairNotExist
+		}
+	}
+
+	//the leeway comes from config unless one is set
+	if st.leeway == nil {
+		lv, err := optionalConfigInt(ctx, bc, "EnvSessLeewaySec", 0)
+		if err != nil {
+			return nil, err
+		}
+
+		leeway := time.Second * time.Duration(lv)
+		st.leeway = &leeway
+	}
+
+	if *st.leeway < 0 {
+		return nil, ErrLeewayInvalid
+	}
+
+	//the key set and stores follow the clock if one is set
+	if st.clock != nil {
+		for _, dep := range []interface{}{keys, st.revocations, st.families, st.sessions} {
+			if cs, ok := dep.(clockSetter); ok {
+				cs.setClock(st.clock)
+			}
+		}
+	} else {
+		st.clock = systemClock{}
+	}
+
+	verifier := &Verifier{
+		keys:        keys,
+		algs:        st.algs,
+		revocations: st.revocations,
+		families:    st.families,
+		roles:       roles,
+		evaluator:   st.evaluator,
+		audience:    st.audience,
+		issuer:      issuer,
+		trusted:     st.trusted,
+		clock:       st.clock,
+		leeway:      *st.leeway,
+		bc:          bc,
+	}
+
+	if err := checkAllowedAlgs(verifier.validMethods(), ""); err != nil {
+		return nil, err
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "newVerifier", "info", "end")
+	}
+
+	return verifier, nil
+}
+
+//validMethods returns the allowlist, or the algorithms of the key sets if no allowlist was set
+func (verifier *Verifier) validMethods() []string {
+	if verifier.algs != nil {
+		return verifier.algs
+	}
+
+	algs := verifier.keys.Algs()
+
+	for _, ks := range verifier.trusted {
+		for _, alg := range ks.Algs() {
+			if !containsString(algs, alg) {
+				algs = append(algs, alg)
+			}
+		}
+	}
+
+	return algs
+}
+
+//issuerKeys returns the key set of the token issuer, tokens of issuers which are not trusted are refused
+func (verifier *Verifier) issuerKeys(iss string) (KeySet, error) {
+	if iss == verifier.issuer {
+		return verifier.keys, nil
+	}
+
+	if ks, ok := verifier.trusted[iss]; ok {
+		return ks, nil
+	}
+
+	return nil, ErrJwtIssuer
+}
+
+//keyLookupErrors are the errors of choosing the key which verifies a token
+var keyLookupErrors = []error{ErrJwtIssuer, ErrJwtInvalidSession, ErrKeyIDNotExist, ErrJwtKeyRevoked, ErrJwtKeyRetired, ErrJwtAlgNotAllowed}
+
+//parseJwt converts a signed jwt string of any token type to its session claims
+func (verifier *Verifier) parseJwt(ctx context.Context, sessionID string) (*SessionClaims, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "parseJwt", "info", "start")
+	}
+
+	//only algorithms on the allowlist are accepted by the parser
+	//the parser does not allow for clock skew, so exp, nbf and iat are checked against our clock below
+	parser := jwt.NewParser(jwt.WithValidMethods(verifier.validMethods()), jwt.WithoutClaimsValidation())
+
+	//the claims are decoded before the key is looked up, so the key set is chosen by the issuer
+	token, err := parser.ParseWithClaims(sessionID, &SessionClaims{}, func(token *jwt.Token) (interface{}, error) {
+		clms, ok := token.Claims.(*SessionClaims)
+		if !ok {
+			return nil, ErrJwtInvalidSession
+		}
+
+		keys, err := verifier.issuerKeys(clms.Issuer)
+		if err != nil {
+			return nil, err
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		return keys.VerificationKey(kid, token.Method.Alg())
+	})
+	if err != nil {
+		//the parser wraps the key lookup errors, which are returned as they are like the other validation errors
+		for _, kerr := range keyLookupErrors {
+			if errors.Is(err, kerr) {
+				return nil, kerr
+			}
+		}
+
+		return nil, err
+	}
+
+	//only return if the token is valid
+	//the iss was checked when its key set was chosen
+	//each application should check its own appclaims
+	if !token.Valid {
+		return nil, ErrJwtInvalidSession
+	}
+
+	clms, ok := token.Claims.(*SessionClaims)
+	if !ok {
+		return nil, ErrJwtInvalidSession
+	}
+
+	if err := verifier.validTimes(clms); err != nil {
+		return nil, err
+	}
+
+	//tokens for other audiences are refused
+	if !verifier.acceptsAudience(clms.Audience) {
+		return nil, ErrJwtAudience
+	}
+
+	//tokens issued before roles were a list carry a delimited role string
+	clms.splitRoleToken(verifier.bc.GetConfigValue(ctx, "EnvSessAppRoleDelim"))
+
+	//reject sessions which have been revoked before their expiry
+	if verifier.revocations != nil {
+		if clms.ID != "" {
+			revoked, err := verifier.revocations.IsRevoked(ctx, clms.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			if revoked {
+				return nil, ErrJwtRevoked
+			}
+		}
+	}
+
+	//reject every token of a family in which refresh token reuse was detected
+	if verifier.families != nil {
+		if clms.Family != "" {
+			revoked, err := verifier.families.IsFamilyRevoked(ctx, clms.Family)
+			if err != nil {
+				return nil, err
+			}
+
+			if revoked {
+				return nil, ErrJwtRevoked
+			}
+		}
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "parseJwt", "info", "end")
+	}
+
+	return clms, nil
+}
+
+//acceptsAudience returns true if no audience is expected, or the token is issued for one of the expected audiences
+func (verifier *Verifier) acceptsAudience(aud []string) bool {
+	if len(verifier.audience) == 0 {
+		return true
+	}
+
+	for _, a := range aud {
+		if containsString(verifier.audience, a) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//containsString returns true if the list holds the value
+func containsString(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
+
+//extractJwt converts a signed jwt string to its session claims, refresh tokens are rejected
+func (verifier *Verifier) extractJwt(ctx context.Context, sessionID string) (*SessionClaims, error) {
+	clms, err := verifier.parseJwt(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	//refresh tokens are not credentials
+	if clms.TokenType == ConstTokenRefresh {
+		return nil, ErrJwtTokenType
+	}
+
+	return clms, nil
+}
+
+//extractRefreshJwt converts a signed refresh token string to its session claims, other token types are rejected
+func (verifier *Verifier) extractRefreshJwt(ctx context.Context, refreshToken string) (*SessionClaims, error) {
+	clms, err := verifier.parseJwt(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if clms.TokenType != ConstTokenRefresh {
+		return nil, ErrJwtTokenType
+	}
+
+	return clms, nil
+}
+
+//grantedRoles returns the roles held by the session and every role they imply through the role hierarchy
+func (verifier *Verifier) grantedRoles(ctx context.Context, sessionID string) ([]string, error) {
+	//extract the token
+	clms, err := verifier.extractJwt(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	//a session without roles cannot hold any role
+	if len(clms.Roles) == 0 {
+		return nil, ErrClaimElementNotExist
+	}
+
+	return verifier.roles.grants(clms.Roles), nil
+}
+
+//CheckUserRole checks that the jwt authorises a given claim
+//the role is held if the session holds it, a role which implies it, or a wildcard role which matches it (eg billing:*)
+func (verifier *Verifier) CheckUserRole(ctx context.Context, sessionID string, roleName string) (bool, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "CheckUserRole", "info", "start")
+	}
+
+	granted, err := verifier.grantedRoles(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "CheckUserRole", "info", "end")
+	}
+
+	return holdsRole(granted, roleName), nil
+}
+
+//HasAnyRole checks that the jwt holds at least one of the roles, as CheckUserRole does
+func (verifier *Verifier) HasAnyRole(ctx context.Context, sessionID string, roleNames ...string) (bool, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "HasAnyRole", "info", "start")
+	}
+
+	granted, err := verifier.grantedRoles(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, roleName := range roleNames {
+		if holdsRole(granted, roleName) {
+			return true, nil
+		}
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "HasAnyRole", "info", "end")
+	}
+
+	return false, nil
+}
+
+//HasAllRoles checks that the jwt holds every one of the roles, as CheckUserRole does
+func (verifier *Verifier) HasAllRoles(ctx context.Context, sessionID string, roleNames ...string) (bool, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "HasAllRoles", "info", "start")
+	}
+
+	granted, err := verifier.grantedRoles(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, roleName := range roleNames {
+		if !holdsRole(granted, roleName) {
+			return false, nil
+		}
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "HasAllRoles", "info", "end")
+	}
+
+	return true, nil
+}
+
+//HoldsAllRoles checks that claims which have already been verified hold every one of the roles, as CheckUserRole does
+//a session without roles holds none of them
+func (verifier *Verifier) HoldsAllRoles(clms *SessionClaims, roleNames ...string) bool {
+	granted := verifier.roles.grants(clms.Roles)
+
+	for _, roleName := range roleNames {
+		if !holdsRole(granted, roleName) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//GetJwtClaim returns the decoded session claims from the session string
+func (verifier *Verifier) GetJwtClaim(ctx context.Context, sessionID string) (*SessionClaims, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "GetJwtClaim", "info", "start")
+	}
+
+	//extract the token
+	clms, err := verifier.extractJwt(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "GetJwtClaim", "info", "end")
+	}
+
+	return clms, nil
+}
+
+//GetJwtClaimElement returns a decoded interface{} from the session string, session claims are returned as their typed field value
+func (verifier *Verifier) GetJwtClaimElement(ctx context.Context, sessionID, element string) (interface{}, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "GetJwtClaimElement", "info", "start")
+	}
+
+	//extract the token
+	clms, err := verifier.extractJwt(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	//get the claim element
+	clm, ok := clms.Claim(element)
+
+	//if it doesn't exist then return error
+	if !ok {
+		return nil, ErrClaimElementNotExist
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "GetJwtClaimElement", "info", "end")
+	}
+
+	return clm, nil
+}
+
+//IsSessionValid returns a bool indicating if the session is still valid
+func (verifier *Verifier) IsSessionValid(ctx context.Context, sessionID string) (bool, error) {
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "IsSessionValid", "info", "start")
+	}
+
+	//extract action checks jwt validity
+	if _, err := verifier.extractJwt(ctx, sessionID); err != nil {
+		return false, err
+	}
+
+	if EnvDebugOn {
+		lblog.LogEvent("Verifier", "IsSessionValid", "info", "end")
+	}
+
+	return true, nil
+}
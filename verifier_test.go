@@ -0,0 +1,357 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lbcf "github.com/lidstromberg/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/net/context"
+)
+
+func createTestVerifierMgr(t *testing.T, ctx context.Context) *SessMgr {
+	sm1, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sm1
+}
+func Test_VerifierFromJWKS(t *testing.T) {
+	ctx := context.Background()
+
+	sm1 := createTestVerifierMgr(t, ctx)
+
+	data, err := json.Marshal(sm1.JWKS(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vf SessVerifier
+	vf, err = NewVerifierFromJWKS(ctx, lbcf.NewConfig(ctx), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chk, err := vf.IsSessionValid(ctx, sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !chk {
+		t.Fatal("session string (jwt) should be valid")
+	}
+
+	result, err := vf.CheckUserRole(ctx, sess, "testapp2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result {
+		t.Fatal("Failed to identify testapp2")
+	}
+
+	eml, err := vf.GetJwtClaimElement(ctx, sess, ConstJwtEml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if eml.(string) != "session@sessiontest.com" {
+		t.Fatal("email claim did not read correctly")
+	}
+
+	//a token from a different issuer key must be rejected
+	sm2 := createTestVerifierMgr(t, ctx)
+
+	sess2, err := sm2.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vf.IsSessionValid(ctx, sess2); err != ErrKeyIDNotExist {
+		t.Fatalf("session signed with an unknown key should be rejected with %v, got %v", ErrKeyIDNotExist, err)
+	}
+}
+func Test_VerifierFromFile(t *testing.T) {
+	ctx := context.Background()
+
+	sm1 := createTestVerifierMgr(t, ctx)
+
+	data, err := json.Marshal(sm1.JWKS(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	vf, err := NewVerifierFromFile(ctx, lbcf.NewConfig(ctx), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vf.GetJwtClaim(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+}
+func Test_VerifierFromURL(t *testing.T) {
+	ctx := context.Background()
+
+	sm1 := createTestVerifierMgr(t, ctx)
+
+	srv := httptest.NewServer(sm1.JWKSHandler(time.Minute))
+	defer srv.Close()
+
+	vf, err := NewVerifierFromURL(ctx, lbcf.NewConfig(ctx), srv.URL, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := sm1.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vf.IsSessionValid(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	if _, err := NewVerifierFromURL(ctx, lbcf.NewConfig(ctx), missing.URL, time.Hour); err == nil {
+		t.Fatal("verifier should not be created if the jwks cannot be fetched")
+	}
+}
+
+func Test_HasRoles(t *testing.T) {
+	ctx := context.Background()
+
+	sm1 := createTestVerifierMgr(t, ctx)
+
+	shdr := createBaseClaims()
+	shdr.Roles = append(shdr.Roles, "billing:admin")
+
+	sess, err := sm1.NewSession(ctx, shdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		roles []string
+		any   bool
+		all   bool
+	}{
+		{"all held", []string{"testapp1", "testapp2"}, true, true},
+		{"some held", []string{"testapp1", "testapp3"}, true, false},
+		{"none held", []string{"testapp3"}, false, false},
+		{"role with delimiter", []string{"billing:admin"}, true, true},
+		{"part of a role", []string{"billing"}, false, false},
+	}
+
+	for _, tc := range cases {
+		anyHeld, err := sm1.HasAnyRole(ctx, sess, tc.roles...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		allHeld, err := sm1.HasAllRoles(ctx, sess, tc.roles...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if anyHeld != tc.any || allHeld != tc.all {
+			t.Fatalf("%s: expected any %v all %v, got any %v all %v", tc.name, tc.any, tc.all, anyHeld, allHeld)
+		}
+	}
+
+	//roles are written as a list
+	clms, err := sm1.GetJwtClaim(ctx, sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(clms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flat := make(map[string]interface{})
+	if err := json.Unmarshal(data, &flat); err != nil {
+		t.Fatal(err)
+	}
+
+	if rle, ok := flat[ConstJwtRole].([]interface{}); !ok || len(rle) != 3 {
+		t.Fatalf("expected a role list, got %v", flat[ConstJwtRole])
+	}
+}
+func Test_LegacyRoleToken(t *testing.T) {
+	ctx := context.Background()
+
+	sm1 := createTestVerifierMgr(t, ctx)
+
+	//a token issued before roles were a list
+	sk := sm1.ring.signingKey()
+
+	signer := jwt.NewWithClaims(sk.Method, jwt.MapClaims{"iss": sm1.issuer, "jti": "s1", "exp": time.Now().Add(time.Minute).Unix(), ConstJwtRole: "testapp1:testapp2"})
+	signer.Header["kid"] = sk.KeyID
+
+	legacy, err := signer.SignedString(sk.SignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := sm1.HasAllRoles(ctx, legacy, "testapp1", "testapp2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatal("legacy role string not read")
+	}
+
+	//the role string becomes a list once the token is re-signed
+	migrated, err := sm1.SetAppClaim(ctx, legacy, "testapp1.editor", "ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clms, err := sm1.GetJwtClaim(ctx, migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clms.Roles) != 2 || clms.Roles[0] != "testapp1" || clms.Roles[1] != "testapp2" {
+		t.Fatalf("unexpected roles: %v", clms.Roles)
+	}
+}
+func Test_IssuerValidation(t *testing.T) {
+	ctx := context.Background()
+
+	sm1 := createTestVerifierMgr(t, ctx)
+
+	sign := func(iss string) string {
+		sk := sm1.ring.signingKey()
+
+		signer := jwt.NewWithClaims(sk.Method, jwt.MapClaims{"iss": iss, "jti": "s1", "exp": time.Now().Add(time.Minute).Unix()})
+		signer.Header["kid"] = sk.KeyID
+
+		token, err := signer.SignedString(sk.SignKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return token
+	}
+
+	if _, err := sm1.IsSessionValid(ctx, sign(sm1.issuer)); err != nil {
+		t.Fatal(err)
+	}
+
+	//tokens signed with our key but carrying another issuer, or none, are refused
+	for _, iss := range []string{"", "other.local"} {
+		if _, err := sm1.IsSessionValid(ctx, sign(iss)); err != ErrJwtIssuer {
+			t.Fatalf("issuer %q: expected %v, got %v", iss, ErrJwtIssuer, err)
+		}
+	}
+}
+func Test_TrustedIssuers(t *testing.T) {
+	ctx := context.Background()
+
+	keys := createTestKeys(t)
+
+	t.Setenv("JWT_ISSUER", "test.local")
+
+	prod, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), keys["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("JWT_ISSUER", "staging.local")
+
+	staging, err := NewMgrWithKey(ctx, lbcf.NewConfig(ctx), createTestKeys(t)["ES256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("JWT_ISSUER", "test.local")
+
+	stagingSess, err := staging.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//staging tokens are refused until staging is trusted
+	if _, err := prod.IsSessionValid(ctx, stagingSess); err != ErrJwtIssuer {
+		t.Fatalf("expected %v, got %v", ErrJwtIssuer, err)
+	}
+
+	vf, err := NewVerifier(ctx, lbcf.NewConfig(ctx), prod.ring, WithTrustedIssuer("staging.local", staging.ring))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prodSess, err := prod.NewSession(ctx, createBaseClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sess := range []string{prodSess, stagingSess} {
+		if _, err := vf.IsSessionValid(ctx, sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//each issuer is verified against its own keys only
+	sk := prod.ring.signingKey()
+
+	signer := jwt.NewWithClaims(sk.Method, jwt.MapClaims{"iss": "staging.local", "jti": "s1", "exp": time.Now().Add(time.Minute).Unix()})
+	signer.Header["kid"] = sk.KeyID
+
+	forged, err := signer.SignedString(sk.SignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vf.IsSessionValid(ctx, forged); err == nil {
+		t.Fatal("a token signed with another issuer's key should be rejected")
+	}
+
+	//a manager reads trusted tokens but does not re-sign them
+	sm1, err := NewMgrWithKeyRing(ctx, lbcf.NewConfig(ctx), prod.ring, WithTrustedIssuer("staging.local", staging.ring))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.GetJwtClaim(ctx, stagingSess); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm1.SetAppClaim(ctx, stagingSess, "testapp1.editor", "ready"); err != ErrJwtIssuer {
+		t.Fatalf("expected %v, got %v", ErrJwtIssuer, err)
+	}
+
+	//our own issuer cannot be trusted with other keys
+	if _, err := NewVerifier(ctx, lbcf.NewConfig(ctx), prod.ring, WithTrustedIssuer("test.local", staging.ring)); err != ErrJwtIssuer {
+		t.Fatalf("expected %v, got %v", ErrJwtIssuer, err)
+	}
+}